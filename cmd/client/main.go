@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/greenpau/go-get-aws-keys/pkg/client"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -16,13 +22,36 @@ func main() {
 	var azureTenantID, azureApplicationID string
 	var adfsHostname string
 	var staticSamlResponse string
+	var isVerifySamlSignature bool
+	var samlMetadataFile string
+	var isRefreshMetadata bool
+	var metadataMaxAge time.Duration
+	var samlClockSkew time.Duration
 	var emailAddress, password string
 	var awsAccountID, awsRole, awsRegion, awsProfileName string
+	var awsPartition string
+	var awsSessionDuration time.Duration
+	var awsOutput string
 	var logLevel string
 	var isShowVersion bool
 	var isNoPrompt bool
+	var isCredentialProcess bool
+	var subcommand string
+	var listenAddress string
+	var serveRefreshWindow time.Duration
+	var imdsHopLimit int
+	var isCacheEnabled bool
+	var isPersistCookies bool
+	var mfaMethod string
+	var mfaTimeout time.Duration
+	var cacheRefreshWindow time.Duration
 	var outputCredFilePath string
 	var outputEnvVarFilePath string
+	var envFormat string
+	var isPrintEnv bool
+	var resultFormat string
+	var outputConfigFilePath string
+	var writeProfileMode string
 	cli := client.New()
 	flag.StringVar(&configFile, "conf-file-name", "", "Path to configuration file")
 	flag.StringVar(&emailAddress, "email", "", "Set email (or username) for authentication")
@@ -31,22 +60,67 @@ func main() {
 	flag.StringVar(&azureApplicationID, "adfs-azure-application-id", "", "Set Azure AWS Application ID for ADFS authentication")
 	flag.StringVar(&adfsHostname, "adfs-enterprise-hostname", "", "Set hostname for enterprise ADFS authentication")
 	flag.StringVar(&staticSamlResponse, "static-saml-file", "", "sets the path to the file with SAML Response claims")
+	flag.BoolVar(&isVerifySamlSignature, "verify-saml-signature", false, "Verify the signature of SAML Response with IdP metadata")
+	flag.StringVar(&samlMetadataFile, "saml-metadata-file", "", "sets the path to the file with IdP metadata for static SAML Response")
+	flag.BoolVar(&isRefreshMetadata, "refresh-metadata", false, "Refresh the cached IdP metadata file")
+	flag.DurationVar(&metadataMaxAge, "metadata-max-age", 0, "The maximum age of the cached IdP metadata file, e.g. 24h")
+	flag.DurationVar(&samlClockSkew, "saml-clock-skew", 0, "The allowed clock skew when validating SAML assertions, e.g. 5m")
+	flag.StringVar(&mfaMethod, "mfa-method", "", "The preferred method of multi-factor authentication: "+strings.Join(client.GetMfaMethods(), ", "))
+	flag.DurationVar(&mfaTimeout, "mfa-timeout", 0, "The time to wait for the approval of multi-factor authentication, e.g. 2m")
 	flag.StringVar(&awsAccountID, "aws-account-id", "", "AWS account ID")
 	flag.StringVar(&awsRole, "aws-iam-role", "", "The name of AWS IAM Role")
-	flag.StringVar(&awsRegion, "aws-region", "us-east-1", "AWS Region")
+	flag.StringVar(&awsRegion, "aws-region", "", "AWS Region, defaults to the main region of the partition of AWS IAM Role")
+	flag.StringVar(&awsPartition, "aws-partition", "", "AWS partition, e.g. "+strings.Join(client.GetAwsPartitionNames(), ", "))
+	flag.DurationVar(&awsSessionDuration, "aws-session-duration", 0, "The duration of AWS session, e.g. 8h, limited by the session duration asserted by IdP")
+	flag.StringVar(&awsOutput, "aws-output", "", "The default output format of AWS CLI for the profile, e.g. json")
 	flag.StringVar(&awsProfileName, "aws-profile-name", "default", "AWS Profile Name")
+	flag.StringVar(&awsProfileName, "profile", "default", "Alias for -aws-profile-name")
 	flag.StringVar(&outputCredFilePath, "output-credentials-file", "~/.aws/credentials", "The path to write AWS credentials to")
-	flag.StringVar(&outputEnvVarFilePath, "output-env-file", "~/.aws/environment", "The path to write AWS environment variables to")
+	flag.StringVar(&outputConfigFilePath, "output-config-file", "~/.aws/config", "The path to write AWS profile settings, e.g. region, to")
+	flag.StringVar(&writeProfileMode, "write-profile", "", "Where to write AWS profiles: "+strings.Join(client.GetAwsProfileWriteModes(), ", ")+" (default: both)")
+	flag.StringVar(&outputEnvVarFilePath, "output-env-file", "", "The path to write AWS environment variables to, e.g. ~/.aws/environment")
+	flag.StringVar(&envFormat, "env-format", "", "The format of AWS environment variables: "+strings.Join(client.GetEnvFormats(), ", ")+" (default: "+client.GetDefaultEnvFormat()+")")
+	flag.BoolVar(&isPrintEnv, "print-env", false, "Print AWS environment variables to stdout, e.g. for eval, instead of writing files")
+	flag.StringVar(&resultFormat, "output", "", "Print the result of the run, or the roles for list-roles, to stdout in a format: "+strings.Join(client.GetResultFormats(), ", "))
+	flag.BoolVar(&isCredentialProcess, "credential-process", false, "Print credential_process JSON document to stdout instead of writing files")
+	flag.BoolVar(&isCacheEnabled, "cache", false, "Reuse cached AWS credentials until they are about to expire")
+	flag.BoolVar(&isPersistCookies, "persist-cookies", false, "Keep IdP session cookies between runs to avoid entering credentials, see logout")
+	flag.DurationVar(&cacheRefreshWindow, "cache-refresh-window", 0, "Refresh cached AWS credentials this long before they expire, e.g. 10m")
+	flag.StringVar(&listenAddress, "listen-address", "127.0.0.1:0", "serve, imds: The loopback address to serve AWS credentials at, e.g. 169.254.169.254:80 for imds")
+	flag.DurationVar(&serveRefreshWindow, "refresh-window", 0, "serve, imds: Refresh the served AWS credentials this long before they expire, e.g. 15m")
+	flag.IntVar(&imdsHopLimit, "hop-limit", client.DefaultImdsHopLimit, "imds: The hop limit of the responses, use 2 for containers on bridge networks")
 	flag.BoolVar(&isNoPrompt, "no-prompt", false, "Disables prompting a user for required information")
 	flag.StringVar(&logLevel, "log-level", "info", "logging severity level")
 	flag.BoolVar(&isShowVersion, "version", false, "version information")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "\n%s - %s\n\n", cli.Info.Name, cli.Info.Description)
-		fmt.Fprintf(os.Stderr, "Usage: %s [arguments]\n\n", cli.Info.Name)
+		fmt.Fprintf(os.Stderr, "Usage: %s [arguments]\n", cli.Info.Name)
+		fmt.Fprintf(os.Stderr, "       %s exec [arguments] -- command [command arguments]\n", cli.Info.Name)
+		fmt.Fprintf(os.Stderr, "       %s serve [arguments]\n", cli.Info.Name)
+		fmt.Fprintf(os.Stderr, "       %s imds [arguments]\n", cli.Info.Name)
+		fmt.Fprintf(os.Stderr, "       %s list-roles [arguments]\n", cli.Info.Name)
+		fmt.Fprintf(os.Stderr, "       %s logout [arguments]\n\n", cli.Info.Name)
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nDocumentation: %s\n\n", cli.Info.Documentation)
 	}
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "exec" || args[0] == "serve" || args[0] == "imds" || args[0] == "list-roles" || args[0] == "logout") {
+		subcommand = args[0]
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	isListRoles := subcommand == "list-roles"
+	isLogout := subcommand == "logout"
+	// These subcommands do not request AWS credentials.
+	isNoRoles := isListRoles || isLogout
+	// These modes output the credentials of one role, selected by profile.
+	isSingleRole := isCredentialProcess || isPrintEnv || outputEnvVarFilePath != "" || (subcommand != "" && !isNoRoles)
+	if resultFormat != "" && (isCredentialProcess || isPrintEnv || (subcommand != "" && !isNoRoles)) {
+		log.Fatalf("-output can not be combined with the modes writing credentials to stdout")
+	}
+	if subcommand == "exec" && flag.NArg() == 0 {
+		log.Fatalf("exec requires a command, e.g. %s exec -profile dev -- aws sts get-caller-identity", cli.Info.Name)
+	}
 	if isShowVersion {
 		fmt.Fprintf(os.Stdout, "%s\n", cli.GetVersionInfo())
 		os.Exit(0)
@@ -69,6 +143,7 @@ func main() {
 	viper.BindEnv("azure.application_id", "GGK_AZURE_AWS_APP_ID")
 	viper.BindEnv("email", "GGK_EMAIL")
 	viper.BindEnv("password", "GGK_PASSWORD")
+	viper.BindEnv("cache.passphrase", "GGK_CACHE_PASSPHRASE")
 	viper.AddConfigPath("$HOME/.aws")
 	viper.AddConfigPath("./config")
 	viper.AddConfigPath(".")
@@ -92,6 +167,95 @@ func main() {
 	if v := viper.Get("static.saml_response_file"); v != nil {
 		staticSamlResponse = v.(string)
 	}
+	if viper.GetBool("saml.verify_signature") {
+		isVerifySamlSignature = true
+	}
+	if samlMetadataFile == "" {
+		if v := viper.Get("saml.metadata_file"); v != nil {
+			samlMetadataFile = v.(string)
+		}
+	}
+	if metadataMaxAge == 0 && viper.IsSet("saml.metadata_max_age") {
+		metadataMaxAge = viper.GetDuration("saml.metadata_max_age")
+	}
+	if mfaMethod == "" {
+		if v := viper.Get("mfa.method"); v != nil {
+			mfaMethod = v.(string)
+		}
+	}
+	if mfaTimeout == 0 && viper.IsSet("mfa.timeout") {
+		mfaTimeout = viper.GetDuration("mfa.timeout")
+	}
+	if viper.GetBool("cache.enabled") {
+		isCacheEnabled = true
+	}
+	if viper.GetBool("cache.cookies") {
+		isPersistCookies = true
+	}
+	if cacheRefreshWindow == 0 && viper.IsSet("cache.refresh_window") {
+		cacheRefreshWindow = viper.GetDuration("cache.refresh_window")
+	}
+	if v := viper.Get("cache.dir"); v != nil {
+		cli.Config.Cache.Dir = v.(string)
+	}
+	if v := viper.Get("cache.key_file"); v != nil {
+		cli.Config.Cache.KeyFile = v.(string)
+	}
+	if v := viper.Get("cache.passphrase"); v != nil {
+		cli.Config.Cache.Passphrase = v.(string)
+	}
+	if writeProfileMode == "" {
+		if v := viper.Get("aws.write_profile"); v != nil {
+			writeProfileMode = v.(string)
+		}
+	}
+	if writeProfileMode != "" {
+		isModeSupported := false
+		for _, mode := range client.GetAwsProfileWriteModes() {
+			if mode == writeProfileMode {
+				isModeSupported = true
+			}
+		}
+		if !isModeSupported {
+			log.Fatalf("unsupported -write-profile mode %s, supported: %v", writeProfileMode, client.GetAwsProfileWriteModes())
+		}
+	}
+	if envFormat == "" {
+		envFormat = viper.GetString("aws.env_format")
+	}
+	if envFormat != "" {
+		isFormatSupported := false
+		for _, format := range client.GetEnvFormats() {
+			if format == envFormat {
+				isFormatSupported = true
+			}
+		}
+		if !isFormatSupported {
+			log.Fatalf("unsupported -env-format %s, supported: %v", envFormat, client.GetEnvFormats())
+		}
+	}
+	if resultFormat != "" {
+		isFormatSupported := false
+		for _, format := range client.GetResultFormats() {
+			if format == resultFormat {
+				isFormatSupported = true
+			}
+		}
+		if !isFormatSupported {
+			log.Fatalf("unsupported -output %s, supported: %v", resultFormat, client.GetResultFormats())
+		}
+	}
+	if viper.IsSet("aws.account_aliases") {
+		cli.Config.Aws.AccountAliases = viper.GetStringMapString("aws.account_aliases")
+	}
+	if awsPartition == "" {
+		if v := viper.Get("aws.partition"); v != nil {
+			awsPartition = v.(string)
+		}
+	}
+	if samlClockSkew == 0 && viper.IsSet("saml.clock_skew") {
+		samlClockSkew = viper.GetDuration("saml.clock_skew")
+	}
 
 	if emailAddress == "" {
 		if v := viper.Get("email"); v != nil {
@@ -116,6 +280,7 @@ func main() {
 			"name":         awsRole,
 			"region":       awsRegion,
 			"profile_name": awsProfileName,
+			"output":       awsOutput,
 		}
 		if err := cli.RequestAwsRole(role); err != nil {
 			log.Fatal(err)
@@ -134,6 +299,19 @@ func main() {
 						cli.Config.Aws.Roles[i].DefaultRegion = v.(string)
 					case "profile_name":
 						cli.Config.Aws.Roles[i].ProfileName = v.(string)
+					case "output":
+						cli.Config.Aws.Roles[i].Output = v.(string)
+					case "config":
+						cli.Config.Aws.Roles[i].Config = map[string]string{}
+						for ck, cv := range v.(map[interface{}]interface{}) {
+							cli.Config.Aws.Roles[i].Config[fmt.Sprint(ck)] = fmt.Sprint(cv)
+						}
+					case "duration":
+						d, err := client.ParseAwsSessionDuration(fmt.Sprint(v))
+						if err != nil {
+							log.Fatal(err)
+						}
+						cli.Config.Aws.Roles[i].Duration = d
 					}
 				}
 			}
@@ -141,6 +319,31 @@ func main() {
 		if err := cli.UpdateAwsRoles(); err != nil {
 			log.Fatal(err)
 		}
+		if isSingleRole && (isFlagSet("aws-profile-name") || isFlagSet("profile")) {
+			if err := cli.SelectAwsProfile(awsProfileName); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	// Let a user pick the roles granted by IdP when no roles are requested.
+	isPickRoles := len(cli.Config.Aws.Roles) == 0 && !isNoRoles
+	if isPickRoles && (isNoPrompt || !client.IsTerminal(os.Stdin)) {
+		log.Fatalf("no AWS roles requested, use -aws-account-id with -aws-iam-role, or aws.roles in configuration; " +
+			"the roles could be picked interactively only when stdin is a terminal, see list-roles for the available roles")
+	}
+	if isCredentialProcess && !isPickRoles && len(cli.Config.Aws.Roles) != 1 {
+		log.Fatalf("credential_process mode requires exactly one AWS role, use -aws-profile-name or -aws-account-id with -aws-iam-role")
+	}
+	if subcommand != "" && !isNoRoles && !isPickRoles && len(cli.Config.Aws.Roles) != 1 {
+		log.Fatalf("%s requires exactly one AWS role, use -profile or -aws-account-id with -aws-iam-role", subcommand)
+	}
+	if (isPrintEnv || outputEnvVarFilePath != "") && !isPickRoles && len(cli.Config.Aws.Roles) != 1 {
+		log.Fatalf("AWS environment variables require exactly one AWS role, use -profile or -aws-account-id with -aws-iam-role")
+	}
+	if awsSessionDuration != 0 {
+		if err := cli.SetAwsSessionDuration(awsSessionDuration); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	/* Populate configuration */
@@ -163,6 +366,61 @@ func main() {
 			log.Fatal(err)
 		}
 	}
+	if isVerifySamlSignature {
+		if err := cli.EnableSamlSignatureVerification(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if samlMetadataFile != "" {
+		if err := cli.SetSamlMetadataFile(samlMetadataFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if isRefreshMetadata {
+		if err := cli.RefreshMetadata(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if metadataMaxAge != 0 {
+		if err := cli.SetMetadataMaxAge(metadataMaxAge); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if isCacheEnabled {
+		if err := cli.EnableCredentialCache(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if isPersistCookies {
+		if err := cli.EnableCookieJar(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if mfaMethod != "" {
+		if err := cli.SetMfaMethod(mfaMethod); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if mfaTimeout != 0 {
+		if err := cli.SetMfaTimeout(mfaTimeout); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cacheRefreshWindow != 0 {
+		if err := cli.SetCredentialCacheRefreshWindow(cacheRefreshWindow); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if awsPartition != "" {
+		if err := cli.SetAwsPartition(awsPartition); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if samlClockSkew != 0 {
+		if err := cli.SetSamlClockSkew(samlClockSkew); err != nil {
+			log.Fatal(err)
+		}
+	}
 	if len(enabledFeatures) == 0 {
 		log.Fatalf("must provide at least one way of obtaining SAML claims")
 	}
@@ -200,6 +458,20 @@ func main() {
 
 	if !isNoPrompt {
 		for _, p := range promptUser {
+			if p == "password" && isLogout {
+				continue
+			}
+			// Prompt for the password only if IdP asks for it, i.e. the
+			// credentials, or the SAML assertions to request them with,
+			// are not in the cache, and IdP session is no longer valid.
+			// The caches are keyed by the username, which is known at
+			// this point. The deferred prompt also serves the refresh of
+			// the credentials by serve or imds command.
+			if p == "password" && (cli.HasIdpSession() || isCacheEnabled &&
+				(!isNoRoles && cli.GetCachedAwsCredentialsForRoles() || cli.GetCachedSamlAssertions())) {
+				cli.DeferPasswordPrompt()
+				continue
+			}
 			if err := cli.InteractiveConfig(p); err != nil {
 				log.Fatalf("%s: failed to interactively prompt a user for %s: %s", cli.Info.Name, p, err)
 			}
@@ -210,15 +482,227 @@ func main() {
 		cli.SetConfigFile(v)
 	}
 
-	awsCredentials, err := cli.GetAwsCredentials()
+	if isLogout {
+		if err := cli.Logout(); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Removed IdP session of %s", cli.Config.Username)
+		return
+	}
+
+	if isListRoles {
+		roles, err := cli.ListAwsRoles()
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, err := client.FormatAwsRoleList(roles, resultFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	var awsCredentials []*client.AwsCredentials
+	var err error
+	if isPickRoles {
+		role := map[string]string{
+			"region":       awsRegion,
+			"profile_name": awsProfileName,
+			"output":       awsOutput,
+		}
+		if awsSessionDuration != 0 {
+			role["duration"] = awsSessionDuration.String()
+		}
+		awsCredentials, err = pickAwsRoles(cli, !isSingleRole, role)
+	} else {
+		awsCredentials, err = cli.GetAwsCredentials()
+	}
 	if err != nil {
+		if resultFormat != "" {
+			printResult(cli.GetLoginResult(err), resultFormat)
+			log.Error(err)
+			os.Exit(1)
+		}
 		log.Fatal(err)
 	}
 
+	if isCredentialProcess {
+		if len(awsCredentials) != 1 {
+			log.Fatalf("credential_process mode expected one set of AWS credentials, received %d", len(awsCredentials))
+		}
+		out, err := awsCredentials[0].GetCredentialProcessOutput()
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	if isPrintEnv {
+		if len(awsCredentials) != 1 {
+			log.Fatalf("-print-env expected one set of AWS credentials, received %d", len(awsCredentials))
+		}
+		out, err := awsCredentials[0].GetEnvVarsScript(envFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	if subcommand == "exec" {
+		if len(awsCredentials) != 1 {
+			log.Fatalf("exec expected one set of AWS credentials, received %d", len(awsCredentials))
+		}
+		code, err := client.ExecWithAwsCredentials(awsCredentials[0], flag.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}
+
+	if subcommand == "serve" || subcommand == "imds" {
+		if len(awsCredentials) != 1 {
+			log.Fatalf("%s expected one set of AWS credentials, received %d", subcommand, len(awsCredentials))
+		}
+		creds := cli.NewRefreshingAwsCredentials()
+		creds.Set(awsCredentials[0])
+		if serveRefreshWindow != 0 {
+			creds.RefreshWindow = serveRefreshWindow
+		}
+		var handler http.Handler
+		var listener net.Listener
+		var envVars []client.EnvVar
+		if subcommand == "serve" {
+			server, err := client.NewEcsCredentialsServer(creds)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if listener, err = server.Listen(listenAddress); err != nil {
+				log.Fatal(err)
+			}
+			handler, envVars = server, server.GetEnvVars(listener.Addr())
+		} else {
+			server := client.NewImdsServer(creds, cli.Config.Aws.Roles[0].Name)
+			server.HopLimit = imdsHopLimit
+			if listener, err = server.Listen(listenAddress); err != nil {
+				log.Fatal(err)
+			}
+			handler, envVars = server, server.GetEnvVars(listener.Addr())
+		}
+		out, err := client.FormatEnvVars(envVars, nil, envFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(out)
+		log.Infof("Serving AWS credentials of %s profile at http://%s", awsCredentials[0].ProfileName, listener.Addr())
+		if err := serveCredentials(listener, handler, creds); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	for i, awsCredential := range awsCredentials {
 		log.Debugf("AWS Access Keys #%d: %v", i, awsCredential)
-		if err := awsCredential.WriteCredentialsFile(outputCredFilePath); err != nil {
+		if err := awsCredential.WriteProfileFiles(outputCredFilePath, outputConfigFilePath, writeProfileMode); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if outputEnvVarFilePath != "" {
+		if len(awsCredentials) != 1 {
+			log.Fatalf("-output-env-file expected one set of AWS credentials, received %d", len(awsCredentials))
+		}
+		if err := awsCredentials[0].WriteEnvVarsFileWithFormat(outputEnvVarFilePath, envFormat); err != nil {
 			log.Fatal(err)
 		}
+		log.Infof("Wrote AWS environment variables for %s profile to %s", awsCredentials[0].ProfileName, outputEnvVarFilePath)
+	}
+	if resultFormat != "" {
+		printResult(cli.GetLoginResult(nil), resultFormat)
+	}
+}
+
+// pickAwsRoles authenticates to IdP, lets a user pick the roles granted by
+// IdP, and assumes the picked roles. The recently used roles come first.
+// The settings of the picked roles, e.g. region, come from the template.
+func pickAwsRoles(cli *client.Client, multi bool, template map[string]string) ([]*client.AwsCredentials, error) {
+	roles, err := cli.ListAwsRoles()
+	if err != nil {
+		return nil, err
+	}
+	client.SortAwsRoleListByHistory(roles, client.ReadAwsRoleHistory(client.DefaultAwsRoleHistoryFile))
+	picked, err := client.PickAwsRoles(roles, multi, os.Stdin, os.Stderr)
+	if err != nil {
+		return nil, err
 	}
+	arns := []string{}
+	for _, role := range picked {
+		reqRole := map[string]string{
+			"account_id": role.AccountID,
+			"name":       role.Name,
+		}
+		for k, v := range template {
+			reqRole[k] = v
+		}
+		// The profile name applies to a single role, the others get
+		// generated names.
+		if len(picked) > 1 {
+			delete(reqRole, "profile_name")
+		}
+		if err := cli.RequestAwsRole(reqRole); err != nil {
+			return nil, err
+		}
+		arns = append(arns, role.RoleARN)
+	}
+	if err := client.UpdateAwsRoleHistory(client.DefaultAwsRoleHistoryFile, arns); err != nil {
+		log.Warnf("Failed to update the history of AWS roles: %s", err)
+	}
+	return cli.GetAwsCredentialsWithSamlAssertions()
+}
+
+// printResult prints the result of the run to stdout.
+func printResult(result *client.LoginResult, format string) {
+	out, err := result.Marshal(format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(out)
+}
+
+// serveCredentials serves the credentials until the tool is interrupted.
+// The credentials are refreshed in background.
+func serveCredentials(listener net.Listener, handler http.Handler, creds *client.RefreshingAwsCredentials) error {
+	stop := make(chan struct{})
+	go creds.Run(stop)
+	defer close(stop)
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Infof("Received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// isFlagSet checks whether a flag was passed on the command line.
+func isFlagSet(name string) bool {
+	isSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			isSet = true
+		}
+	})
+	return isSet
 }
@@ -25,6 +25,9 @@ func NewAdfsAuthFormFromString(s string) (*AdfsAuthForm, error) {
 // NewAdfsAuthFormFromBytes returns AdfsAuthForm instance from an input byte array.
 func NewAdfsAuthFormFromBytes(s []byte) (*AdfsAuthForm, error) {
 	authForm := AdfsAuthForm{}
+	// The login form of an IdP-initiated sign-on page has a relative
+	// action. It is used when the page has no "options" form.
+	var loginFormURL string
 	r := bytes.NewReader(s)
 	iterator := html.NewTokenizer(r)
 	for {
@@ -37,14 +40,20 @@ func NewAdfsAuthFormFromBytes(s []byte) (*AdfsAuthForm, error) {
 			isForm := t.Data == "form"
 			if isForm {
 				validAttrCount := 0
+				isLoginForm := false
+				var loginFormAction string
 				for _, attr := range t.Attr {
 					if attr.Key == "id" && attr.Val == "options" {
 						validAttrCount++
-					} else if attr.Key == "method" && attr.Val == "post" {
+					} else if attr.Key == "id" && attr.Val == "loginForm" {
+						isLoginForm = true
+					} else if attr.Key == "method" && strings.ToLower(attr.Val) == "post" {
 						validAttrCount++
 					} else if attr.Key == "action" && strings.HasPrefix(attr.Val, "http") {
 						validAttrCount++
 						authForm.URL = attr.Val
+					} else if attr.Key == "action" && attr.Val != "" {
+						loginFormAction = attr.Val
 					} else {
 						continue
 					}
@@ -59,8 +68,38 @@ func NewAdfsAuthFormFromBytes(s []byte) (*AdfsAuthForm, error) {
 					authForm.Port = port
 					return &authForm, nil
 				}
+				if isLoginForm && loginFormURL == "" {
+					loginFormURL = authForm.URL
+					if loginFormAction != "" {
+						loginFormURL = loginFormAction
+					}
+				}
+				authForm.URL = ""
 			}
 		}
 	}
+	if loginFormURL != "" {
+		authForm.URL = loginFormURL
+		return &authForm, nil
+	}
 	return nil, fmt.Errorf("Authentication form not found")
 }
+
+// Resolve resolves the URL of the form relative to the URL of the page
+// the form was found on, and updates the host and port of the form.
+func (f *AdfsAuthForm) Resolve(base *url.URL) error {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return fmt.Errorf("Failed to parse URL: %s", f.URL)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !strings.HasPrefix(u.Scheme, "http") {
+		return fmt.Errorf("Failed to resolve URL: %s", f.URL)
+	}
+	f.URL = u.String()
+	f.Host = u.Hostname()
+	f.Port = u.Port()
+	return nil
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"io/ioutil"
+	"net/url"
 	"path"
 	"testing"
 )
@@ -11,13 +12,30 @@ func TestParseAdfsAuthForm(t *testing.T) {
 	assetDir := "../../assets/tests"
 	for i, test := range []struct {
 		input      string
+		base       string
 		exp        *AdfsAuthForm
 		shouldFail bool
 		shouldErr  bool
 	}{
 		{
 			input: "adfs.auth.form.html",
+			base:  "https://adfs.contoso.com/adfs/ls/?client-request-id=d88d1ebc-ac69-4fce-91e5-5628eacf325b",
 			exp: &AdfsAuthForm{
+				URL: "https://adfs.contoso.com:443/adfs/ls/?client-request-id=d88d1ebc-ac69-4fce-91e5-5628eacf325b" +
+					"&username=&wa=wsignin1.0&wtrealm=urn%3afederation%3aMicrosoftOnline" +
+					"&wctx=estsredirect%3d2%26estsrequest%3d6552c216e13043ca8d640912c06ac3d4",
+				Host: "adfs.contoso.com",
+				Port: "443",
+			},
+			shouldFail: false,
+			shouldErr:  false,
+		},
+		{
+			input: "adfs.idp.initiated.signon.html",
+			base:  "https://adfs.contoso.com/adfs/ls/IdpInitiatedSignOn.aspx?loginToRp=urn:amazon:webservices",
+			exp: &AdfsAuthForm{
+				URL: "https://adfs.contoso.com/adfs/ls/IdpInitiatedSignOn.aspx?loginToRp=urn:amazon:webservices" +
+					"&client-request-id=0b3c6a3e-52c0-4a4f-a3a9-7f0a7e1c9d21",
 				Host: "adfs.contoso.com",
 			},
 			shouldFail: false,
@@ -46,9 +64,18 @@ func TestParseAdfsAuthForm(t *testing.T) {
 			}
 		}
 
-		if (resp.Host != test.exp.Host) && !test.shouldFail {
+		// The login form of IdP-initiated sign-on page has a relative
+		// action, resolved against the address of the page.
+		base, _ := url.Parse(test.base)
+		if err := resp.Resolve(base); err != nil {
+			t.Logf("FAIL: Test %d: input '%s', failed resolving form URL: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+
+		if (resp.URL != test.exp.URL || resp.Host != test.exp.Host || resp.Port != test.exp.Port) && !test.shouldFail {
 			t.Logf("FAIL: Test %d: input '%s', expected to pass, but failed due to mismatch %s (expected) vs %s (file)",
-				i, test.input, test.exp.Host, resp.Host)
+				i, test.input, test.exp.URL, resp.URL)
 			testFailed++
 			continue
 		}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxAdfsAutoPostForms is the maximum number of auto-post forms, e.g.
+// RequestSecurityTokenResponse forms of chained ADFS instances, the
+// client follows before it receives SAMLResponse.
+const maxAdfsAutoPostForms = 5
+
+// DoAdfsAuthnRequest authenticates to an enterprise ADFS instance via
+// IdP-initiated sign-on and receives SAML assertions back.
+func (c *Client) DoAdfsAuthnRequest() error {
+	// Step 1: Request the IdP-initiated sign-on page. The page contains
+	// the login form, unless ADFS session is valid.
+	req, err := http.NewRequest("GET", c.Runtime.AuthenticationURL, nil)
+	if err != nil {
+		return fmt.Errorf("Error creating http get request: %s", err)
+	}
+	resp, err := c.browser.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error authenticating @ %s: %s", c.Runtime.AuthenticationURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading response data from %s: %s", c.Runtime.AuthenticationURL, err)
+	}
+	log.Debugf("ADFS responded with %s: %s", resp.Status, string(body[:]))
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("ADFS sign-on page %s responded with %s", c.Runtime.AuthenticationURL, resp.Status)
+	}
+	// pageURL is the address of the current page, i.e. after redirects.
+	pageURL := resp.Request.URL
+	if authForm, err := NewAdfsAuthFormFromBytes(body); err == nil {
+		if err := authForm.Resolve(resp.Request.URL); err != nil {
+			return fmt.Errorf("Error parsing response: %s", err)
+		}
+		log.Debugf("ADFS Authentication Form: %v", authForm)
+
+		// Step 2: Post credentials to the login form. Upon successful
+		// authentication, ADFS responds with an auto-post form.
+		if err := c.requirePassword(); err != nil {
+			return err
+		}
+		formData, err := c.GetAdfsAuthenticationRequestBody()
+		if err != nil {
+			return err
+		}
+		pageURL, body, err = c.postAdfsForm(authForm.URL, formData)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Without the login form, the session cookie, e.g. MSISAuth, is
+		// expected to be valid, and the page is an auto-post form.
+		log.Debugf("ADFS did not ask for credentials, reusing ADFS session of %s", c.Config.Username)
+	}
+
+	// Step 3: Follow the auto-post forms until the form containing
+	// SAMLResponse is found.
+	for i := 0; i < maxAdfsAutoPostForms; i++ {
+		// With MFA adapter, ADFS responds with the additional
+		// authentication page after the credentials.
+		if _, err := NewAdfsMfaFormFromBytes(body); err == nil {
+			if pageURL, body, err = c.DoAdfsMfa(pageURL, body); err != nil {
+				return err
+			}
+		}
+		if bytes.Contains(body, []byte("\"SAMLResponse\"")) {
+			samlResponseForm, err := NewAzureAuthResponseFormFromBytes(body)
+			if err != nil {
+				return fmt.Errorf("Error reading response form data from %s: %s", pageURL, err)
+			}
+			log.Debugf("ADFS SAML Response Form: %v", samlResponseForm)
+			// Step 4: Decode SAMLResponse for the submission to AWS STS Endpoint.
+			return c.DecodeSamlResponse(samlResponseForm.Fields["SAMLResponse"], "ADFS SAML Response Form")
+		}
+		authResponseForm, err := NewAdfsAuthResponseFormFromBytes(body)
+		if err != nil {
+			if _, loginFormErr := NewAdfsAuthFormFromBytes(body); loginFormErr == nil {
+				return fmt.Errorf("ADFS form-based authentication failed for %s", c.Config.Username)
+			}
+			return fmt.Errorf("Error reading form data from %s: %s", pageURL, err)
+		}
+		log.Debugf("ADFS Authentication Response Form: %v", authResponseForm)
+		formEntries := url.Values{}
+		for k, v := range authResponseForm.Fields {
+			formEntries.Set(k, v)
+		}
+		pageURL, body, err = c.postAdfsForm(authResponseForm.URL, formEntries)
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("ADFS authentication response does not contain SAMLResponse after %d forms", maxAdfsAutoPostForms)
+}
+
+// postAdfsForm submits form data to ADFS and returns the address and the
+// body of the response.
+func (c *Client) postAdfsForm(u string, v url.Values) (*url.URL, []byte, error) {
+	encodedFormData := v.Encode()
+	log.Debugf("ADFS POST URL: %s", u)
+	req, err := http.NewRequest("POST", u, strings.NewReader(encodedFormData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error creating http post request: %s", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(encodedFormData)))
+	resp, err := c.browser.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error authenticating @ %s: %s", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading response data from %s: %s", u, err)
+	}
+	log.Debugf("ADFS responded with %s: %s", resp.Status, string(body[:]))
+	if resp.StatusCode != 200 {
+		return nil, nil, fmt.Errorf("ADFS form-based authentication failed @ %s: %s", u, resp.Status)
+	}
+	return resp.Request.URL, body, nil
+}
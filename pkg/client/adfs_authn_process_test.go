@@ -0,0 +1,122 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+// newTestAdfsServer returns the server mimicking ADFS IdP-initiated
+// sign-on. The credentials are accepted for the password "secret", and
+// the session cookie skips the login form afterwards.
+func newTestAdfsServer(t *testing.T) *httptest.Server {
+	signOnPage, err := ioutil.ReadFile(path.Join("../../assets/tests", "adfs.idp.initiated.signon.html"))
+	if err != nil {
+		t.Fatalf("failed reading sign-on page: %v", err)
+	}
+	samlResponse, err := ioutil.ReadFile(path.Join("../../assets/tests", "saml2.response.roles.xml"))
+	if err != nil {
+		t.Fatalf("failed reading SAML response: %v", err)
+	}
+	var server *httptest.Server
+	// The auto-post form with RequestSecurityTokenResponse, e.g. of a
+	// chained ADFS instance.
+	autoPostForm := func(w http.ResponseWriter) {
+		fmt.Fprintf(w, `<html><body><form method="POST" name="hiddenform" action="%s/adfs/ls/rp">`+
+			`<input type="hidden" name="wa" value="wsignin1.0" />`+
+			`<input type="hidden" name="wresult" value="&lt;t:RequestSecurityTokenResponse/&gt;" />`+
+			`<input type="hidden" name="wctx" value="rpctx" /></form></body></html>`, server.URL)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/adfs/ls/IdpInitiatedSignOn.aspx", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("loginToRp") != "urn:amazon:webservices" {
+			http.Error(w, "unexpected relying party", http.StatusBadRequest)
+			return
+		}
+		if r.Method == "GET" {
+			if c, err := r.Cookie("MSISAuth"); err == nil && c.Value == "session" {
+				autoPostForm(w)
+				return
+			}
+			w.Write(signOnPage)
+			return
+		}
+		// The login form posts back to the sign-on page.
+		r.ParseForm()
+		if r.URL.Query().Get("client-request-id") != "0b3c6a3e-52c0-4a4f-a3a9-7f0a7e1c9d21" ||
+			r.Form.Get("UserName") != "jsmith@contoso.com" || r.Form.Get("AuthMethod") != "FormsAuthentication" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("Password") != "secret" {
+			w.Write(signOnPage)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "MSISAuth", Value: "session", Path: "/adfs"})
+		autoPostForm(w)
+	})
+	mux.HandleFunc("/adfs/ls/rp", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("wa") != "wsignin1.0" || r.Form.Get("wctx") != "rpctx" || r.Form.Get("wresult") == "" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `<html><body><form method="POST" name="hiddenform" action="https://signin.aws.amazon.com/saml">`+
+			`<input type="hidden" name="SAMLResponse" value="%s" /></form></body></html>`,
+			base64.StdEncoding.EncodeToString(samlResponse))
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func TestDoAdfsAuthnRequest(t *testing.T) {
+	server := newTestAdfsServer(t)
+	defer server.Close()
+	cli := New()
+	testFailed := 0
+	for i, test := range []struct {
+		password   string
+		shouldFail bool
+		err        string
+	}{
+		{password: "wrong", shouldFail: true, err: "ADFS form-based authentication failed"},
+		{password: "secret"},
+		// The session cookie of the previous test is valid, and the
+		// password is not needed.
+		{password: ""},
+	} {
+		cli.Config.Username = "jsmith@contoso.com"
+		cli.Config.Password = test.password
+		cli.Runtime.AuthenticationURL = server.URL + "/adfs/ls/IdpInitiatedSignOn.aspx?loginToRp=urn:amazon:webservices"
+		cli.Runtime.Saml.Attributes = nil
+		err := cli.DoAdfsAuthnRequest()
+		if err != nil {
+			if !test.shouldFail || !strings.Contains(err.Error(), test.err) {
+				t.Logf("FAIL: Test %d: password %s, unexpected error: %v", i, test.password, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: password %s, expected to fail, failed: %v", i, test.password, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: password %s, expected to fail, but passed", i, test.password)
+			testFailed++
+			continue
+		}
+		if cli.Runtime.Saml.Attributes == nil || len(cli.Runtime.Saml.Attributes.Aws.Roles) != 3 {
+			t.Logf("FAIL: Test %d: password %s, SAML assertions were not received", i, test.password)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: password %s, expected to pass, passed", i, test.password)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
@@ -1,24 +1,79 @@
 package client
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"time"
 )
 
-// GetAdfsMetadata fetches the matadata about an inssuer.
+// DefaultMetadataMaxAge is the maximum age of the cached metadata file
+// when neither the metadata nor the configuration limit it.
+const DefaultMetadataMaxAge = 24 * time.Hour
+
+// GetAdfsMetadata fetches the matadata about an inssuer. The metadata is
+// cached in a file and the file is refreshed when it expires.
 func (c *Client) GetAdfsMetadata() error {
 	if !c.IsMetadataNeeded() {
 		return nil
 	}
+	var cachedMetadata *SamlServiceMetadata
 	if c.IsMetadataExists() {
 		log.Debugf("Metadata file exists: %s", c.Runtime.Metadata.File.Path)
 		if err := c.ReadMetadataFromFile(); err != nil {
 			return fmt.Errorf("Error reading metadata from %s: %s", c.Runtime.Metadata.File.Path, err)
 		}
+		if err := c.ParseMetadata(); err != nil {
+			if c.Runtime.Metadata.URL == "" {
+				return err
+			}
+			log.Warnf("Discarding cached metadata: %s", err)
+		} else {
+			if c.Runtime.Metadata.URL == "" {
+				return nil
+			}
+			isStale, reason := c.IsMetadataStale()
+			if !isStale {
+				return nil
+			}
+			log.Debugf("Refreshing metadata file %s: %s", c.Runtime.Metadata.File.Path, reason)
+			m := c.Runtime.Metadata
+			cachedMetadata = &m
+		}
+	}
+	if c.Runtime.Metadata.URL == "" {
+		if c.Runtime.Metadata.File.Path == "" {
+			return fmt.Errorf("Metadata file is not set, use saml.metadata_file configuration key")
+		}
+		return fmt.Errorf("Metadata file not found: %s", c.Runtime.Metadata.File.Path)
+	}
+	if err := c.DownloadMetadata(); err != nil {
+		if cachedMetadata == nil {
+			return err
+		}
+		validUntil := cachedMetadata.Entity.GetValidUntil()
+		if !validUntil.IsZero() && time.Now().After(validUntil) {
+			return fmt.Errorf("%s, and the cached metadata expired at %s", err, validUntil)
+		}
+		log.Warnf("%s, using cached metadata from %s", err, cachedMetadata.File.Path)
+		c.Runtime.Metadata = *cachedMetadata
 		return nil
 	}
+	if cachedMetadata != nil {
+		cachedMetadata.CompareSigningCertificates(&c.Runtime.Metadata)
+	}
+	if err := c.WriteMetadataToFile(); err != nil {
+		return fmt.Errorf("Error writing metadata to %s: %s", c.Runtime.Metadata.File.Path, err)
+	}
+	return nil
+}
+
+// DownloadMetadata fetches the matadata about an inssuer from its URL.
+func (c *Client) DownloadMetadata() error {
 	resp, err := http.Get(c.Runtime.Metadata.URL)
 	if err != nil {
 		return fmt.Errorf("Error querying metadata @ %s: %s", c.Runtime.Metadata.URL, err)
@@ -28,10 +83,109 @@ func (c *Client) GetAdfsMetadata() error {
 	if err != nil {
 		return fmt.Errorf("Error reading response data from %s: %s", c.Runtime.Metadata.URL, err)
 	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Error querying metadata @ %s: %s", c.Runtime.Metadata.URL, resp.Status)
+	}
+	entity, err := NewEntityDescriptorFromBytes(body)
+	if err != nil {
+		return fmt.Errorf("Error parsing metadata from %s: %s", c.Runtime.Metadata.URL, err)
+	}
 	c.Runtime.Metadata.Raw = body
 	c.Runtime.Metadata.Plain = string(c.Runtime.Metadata.Raw[:])
-	if err := c.WriteMetadataToFile(); err != nil {
-		return fmt.Errorf("Error writing metadata to %s: %s", c.Runtime.Metadata.File.Path, err)
+	c.Runtime.Metadata.Entity = entity
+	log.Debugf("Metadata entity ID: %s", entity.EntityID)
+	return nil
+}
+
+// IsMetadataStale checks whether the cached metadata file must be
+// refreshed. The metadata is stale when it is past its validUntil, when
+// the file is older than the cacheDuration of the metadata or the
+// configured maximum age, or when the refresh is requested explicitly.
+func (c *Client) IsMetadataStale() (bool, string) {
+	if c.Config.Saml.RefreshMetadata {
+		return true, "refresh requested"
+	}
+	if c.Runtime.Metadata.Entity == nil {
+		return true, "metadata not parsed"
+	}
+	now := time.Now()
+	validUntil := c.Runtime.Metadata.Entity.GetValidUntil()
+	if !validUntil.IsZero() && now.After(validUntil) {
+		return true, fmt.Sprintf("metadata expired at %s", validUntil)
+	}
+	fi, err := os.Stat(c.Runtime.Metadata.File.Path)
+	if err != nil {
+		return true, err.Error()
+	}
+	maxAge := c.Config.Saml.MetadataMaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMetadataMaxAge
+	}
+	if cacheDuration, err := c.Runtime.Metadata.Entity.GetCacheDuration(); err == nil && cacheDuration > 0 && cacheDuration < maxAge {
+		maxAge = cacheDuration
+	}
+	if age := now.Sub(fi.ModTime()); age > maxAge {
+		return true, fmt.Sprintf("metadata file age %s exceeds %s", age.Round(time.Second), maxAge)
+	}
+	return false, ""
+}
+
+// CompareSigningCertificates compares the signing certificates of the
+// metadata with the ones in the refreshed metadata and warns about the
+// changes, e.g. certificate rollover.
+func (m *SamlServiceMetadata) CompareSigningCertificates(refreshed *SamlServiceMetadata) bool {
+	fingerprints := func(metadata *SamlServiceMetadata) map[string]*x509.Certificate {
+		entries := map[string]*x509.Certificate{}
+		certs, err := metadata.GetSigningCertificates()
+		if err != nil {
+			return entries
+		}
+		for _, cert := range certs {
+			entries[fmt.Sprintf("%x", sha256.Sum256(cert.Raw))] = cert
+		}
+		return entries
+	}
+	current := fingerprints(m)
+	updated := fingerprints(refreshed)
+	isChanged := false
+	for k, cert := range current {
+		if _, exists := updated[k]; !exists {
+			log.Warnf("IdP signing certificate removed from metadata: %s (SHA256 %s, expires %s)", cert.Subject, k, cert.NotAfter)
+			isChanged = true
+		}
+	}
+	for k, cert := range updated {
+		if _, exists := current[k]; !exists {
+			log.Warnf("IdP signing certificate added to metadata: %s (SHA256 %s, expires %s)", cert.Subject, k, cert.NotAfter)
+			isChanged = true
+		}
+	}
+	return isChanged
+}
+
+// ParseMetadata parses the metadata about an issuer into EntityDescriptor.
+func (c *Client) ParseMetadata() error {
+	entity, err := NewEntityDescriptorFromBytes(c.Runtime.Metadata.Raw)
+	if err != nil {
+		return fmt.Errorf("Error parsing metadata from %s: %s", c.Runtime.Metadata.URL, err)
+	}
+	c.Runtime.Metadata.Entity = entity
+	log.Debugf("Metadata entity ID: %s", entity.EntityID)
+	if endpoint, err := entity.GetSingleSignOnService(); err == nil {
+		log.Debugf("Metadata single sign-on service: %s (%s)", endpoint.Location, endpoint.Binding)
 	}
 	return nil
 }
+
+// GetSigningCertificates returns the certificates the IdP uses to sign
+// SAML assertions.
+func (m *SamlServiceMetadata) GetSigningCertificates() ([]*x509.Certificate, error) {
+	if m.Entity == nil {
+		entity, err := NewEntityDescriptorFromBytes(m.Raw)
+		if err != nil {
+			return nil, err
+		}
+		m.Entity = entity
+	}
+	return m.Entity.GetCertificates("signing")
+}
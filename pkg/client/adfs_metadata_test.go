@@ -0,0 +1,111 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestIsMetadataStale(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	tmpDir, err := ioutil.TempDir("", "ggk-metadata")
+	if err != nil {
+		t.Fatalf("failed creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	content, err := ioutil.ReadFile(path.Join(assetDir, "adfs.federation.metadata.xml"))
+	if err != nil {
+		t.Fatalf("failed reading metadata: %v", err)
+	}
+	for i, test := range []struct {
+		age     time.Duration
+		maxAge  time.Duration
+		refresh bool
+		isStale bool
+	}{
+		{age: time.Hour, isStale: false},
+		// The cacheDuration of the metadata is 24 hours.
+		{age: 25 * time.Hour, maxAge: 48 * time.Hour, isStale: true},
+		{age: 2 * time.Hour, maxAge: time.Hour, isStale: true},
+		{age: time.Minute, refresh: true, isStale: true},
+	} {
+		cli := New()
+		cli.Runtime.Metadata.File.Path = path.Join(tmpDir, "adfs.enterprise.adfs.contoso.com.metadata.xml")
+		cli.Runtime.Metadata.Raw = content
+		if err := cli.WriteMetadataToFile(); err != nil {
+			t.Logf("FAIL: Test %d: failed writing metadata: %v", i, err)
+			testFailed++
+			continue
+		}
+		mtime := time.Now().Add(-1 * test.age)
+		if err := os.Chtimes(cli.Runtime.Metadata.File.Path, mtime, mtime); err != nil {
+			t.Logf("FAIL: Test %d: failed changing metadata file time: %v", i, err)
+			testFailed++
+			continue
+		}
+		if err := cli.ParseMetadata(); err != nil {
+			t.Logf("FAIL: Test %d: failed parsing metadata: %v", i, err)
+			testFailed++
+			continue
+		}
+		cli.Config.Saml.MetadataMaxAge = test.maxAge
+		cli.Config.Saml.RefreshMetadata = test.refresh
+		isStale, reason := cli.IsMetadataStale()
+		if isStale != test.isStale {
+			t.Logf("FAIL: Test %d: stale mismatch %t (expected) vs %t, reason: %s", i, test.isStale, isStale, reason)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: stale %t, reason: %s", i, isStale, reason)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestCompareSigningCertificates(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		current   string
+		refreshed string
+		isChanged bool
+	}{
+		{
+			current:   "adfs.federation.metadata.xml",
+			refreshed: "adfs.federation.metadata.xml",
+			isChanged: false,
+		},
+		{
+			current:   "adfs.federation.metadata.xml",
+			refreshed: "adfs.federation.metadata.untrusted.xml",
+			isChanged: true,
+		},
+	} {
+		current := &SamlServiceMetadata{}
+		refreshed := &SamlServiceMetadata{}
+		var err error
+		if current.Raw, err = ioutil.ReadFile(path.Join(assetDir, test.current)); err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, test.current, err)
+			testFailed++
+			continue
+		}
+		if refreshed.Raw, err = ioutil.ReadFile(path.Join(assetDir, test.refreshed)); err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, test.refreshed, err)
+			testFailed++
+			continue
+		}
+		if isChanged := current.CompareSigningCertificates(refreshed); isChanged != test.isChanged {
+			t.Logf("FAIL: Test %d: change mismatch %t (expected) vs %t", i, test.isChanged, isChanged)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: %s vs %s, expected to pass, passed", i, test.current, test.refreshed)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The authentication methods of ADFS MFA adapters, i.e. the value of
+// AuthMethod field of the additional authentication page.
+const (
+	AdfsMfaAzure       = "AzureMfaAuthentication"
+	AdfsMfaAzureServer = "AzureMfaServerAuthentication"
+	AdfsMfaDuo         = "DuoAdfsAdapter"
+)
+
+// AdfsMfaForm is the additional authentication page of ADFS, displayed by
+// an MFA adapter after forms authentication.
+type AdfsMfaForm struct {
+	URL        string
+	AuthMethod string
+	Fields     map[string]string
+	// ErrorText is the message ADFS displays, e.g. when the verification
+	// code is incorrect.
+	ErrorText string
+	// Duo is the Duo Web prompt of Duo adapter.
+	Duo *DuoFrame
+}
+
+// NewAdfsMfaFormFromString returns AdfsMfaForm instance from an input string.
+func NewAdfsMfaFormFromString(s string) (*AdfsMfaForm, error) {
+	return NewAdfsMfaFormFromBytes([]byte(s))
+}
+
+// NewAdfsMfaFormFromBytes returns AdfsMfaForm instance from an input byte
+// array. The page is the additional authentication page when it has a
+// form with AuthMethod and Context fields, and the method is not forms
+// authentication.
+func NewAdfsMfaFormFromBytes(s []byte) (*AdfsMfaForm, error) {
+	var mfaForm *AdfsMfaForm
+	var form *AdfsMfaForm
+	var duo *DuoFrame
+	var errorText string
+	isErrorText := false
+	iterator := html.NewTokenizer(bytes.NewReader(s))
+	for {
+		tt := iterator.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := iterator.Token()
+			attrs := map[string]string{}
+			for _, attr := range t.Attr {
+				attrs[attr.Key] = attr.Val
+			}
+			switch t.Data {
+			case "form":
+				form = &AdfsMfaForm{URL: attrs["action"], Fields: map[string]string{}}
+			case "input":
+				if form != nil && attrs["name"] != "" {
+					form.Fields[attrs["name"]] = attrs["value"]
+				}
+			case "iframe":
+				if attrs["id"] == "duo_iframe" {
+					duo = &DuoFrame{
+						Host:       attrs["data-host"],
+						SigRequest: attrs["data-sig-request"],
+						PostAction: attrs["data-post-action"],
+					}
+				}
+			}
+			if attrs["id"] == "errorText" && tt == html.StartTagToken {
+				isErrorText = true
+			}
+		case html.TextToken:
+			if isErrorText {
+				errorText += strings.TrimSpace(string(iterator.Text()))
+			}
+		case html.EndTagToken:
+			isErrorText = false
+			t := iterator.Token()
+			if t.Data != "form" || form == nil {
+				continue
+			}
+			_, hasContext := form.Fields["Context"]
+			authMethod := form.Fields["AuthMethod"]
+			if mfaForm == nil && hasContext && authMethod != "" && authMethod != "FormsAuthentication" {
+				form.AuthMethod = authMethod
+				mfaForm = form
+			}
+			form = nil
+		}
+	}
+	if mfaForm == nil {
+		return nil, fmt.Errorf("ADFS additional authentication form not found")
+	}
+	mfaForm.ErrorText = errorText
+	mfaForm.Duo = duo
+	return mfaForm, nil
+}
+
+// Resolve resolves the URL of the form relative to the URL of the page
+// the form was found on.
+func (f *AdfsMfaForm) Resolve(base *url.URL) error {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return fmt.Errorf("Failed to parse URL: %s", f.URL)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !strings.HasPrefix(u.Scheme, "http") {
+		return fmt.Errorf("Failed to resolve URL: %s", f.URL)
+	}
+	f.URL = u.String()
+	return nil
+}
+
+// DoAdfsMfa goes through the additional authentication of ADFS MFA adapter
+// on the page received from the address u. It returns the address and the
+// body of the page following the authentication, e.g. the form with
+// RequestSecurityTokenResponse.
+func (c *Client) DoAdfsMfa(u *url.URL, page []byte) (*url.URL, []byte, error) {
+	deadline := time.Now().Add(c.getMfaTimeout())
+	isPosted := false
+	isPushNotified := false
+	for {
+		form, err := NewAdfsMfaFormFromBytes(page)
+		if err != nil {
+			// The additional authentication is over.
+			return u, page, nil
+		}
+		if err := form.Resolve(u); err != nil {
+			return nil, nil, err
+		}
+		log.Debugf("ADFS Additional Authentication Form: %v", form)
+		if isPosted && form.ErrorText != "" {
+			return nil, nil, fmt.Errorf("ADFS multi-factor authentication failed for %s: %s", c.Config.Username, form.ErrorText)
+		}
+		formData := url.Values{}
+		for k, v := range form.Fields {
+			formData.Set(k, v)
+		}
+		switch form.AuthMethod {
+		case AdfsMfaAzure, AdfsMfaAzureServer:
+			if _, exists := form.Fields["VerificationCode"]; exists {
+				if isPosted {
+					return nil, nil, fmt.Errorf("ADFS multi-factor authentication failed for %s: the verification code was not accepted", c.Config.Username)
+				}
+				code, err := c.promptMfaCode("Enter the verification code: ")
+				if err != nil {
+					return nil, nil, err
+				}
+				formData.Set("VerificationCode", code)
+				break
+			}
+			// ADFS sends a push notification or calls a user, and responds
+			// with the same page until the sign-in is approved.
+			if !isPushNotified {
+				fmt.Fprintf(os.Stderr, "Approve the sign-in in Microsoft Authenticator\n")
+				isPushNotified = true
+			}
+			if isPosted {
+				if time.Now().Add(mfaPollInterval).After(deadline) {
+					return nil, nil, fmt.Errorf("ADFS multi-factor authentication failed for %s: the sign-in was not approved within %s",
+						c.Config.Username, c.getMfaTimeout())
+				}
+				time.Sleep(mfaPollInterval)
+			}
+		case AdfsMfaDuo:
+			if isPosted {
+				return nil, nil, fmt.Errorf("ADFS multi-factor authentication failed for %s: Duo response was not accepted", c.Config.Username)
+			}
+			if form.Duo == nil {
+				return nil, nil, fmt.Errorf("ADFS Duo adapter page does not contain Duo prompt")
+			}
+			sigResponse, err := c.DoDuoAuth(form.Duo, u.String())
+			if err != nil {
+				return nil, nil, err
+			}
+			formData.Set("sig_response", sigResponse)
+			if form.Duo.PostAction != "" {
+				postURL, err := u.Parse(form.Duo.PostAction)
+				if err != nil {
+					return nil, nil, fmt.Errorf("Failed to parse URL: %s", form.Duo.PostAction)
+				}
+				form.URL = postURL.String()
+			}
+		default:
+			return nil, nil, fmt.Errorf("ADFS MFA adapter %s is not supported", form.AuthMethod)
+		}
+		u, page, err = c.postAdfsForm(form.URL, formData)
+		if err != nil {
+			return nil, nil, err
+		}
+		isPosted = true
+	}
+}
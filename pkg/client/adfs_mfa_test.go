@@ -0,0 +1,234 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAdfsMfaFormFromString(t *testing.T) {
+	fp := path.Join("../../assets/tests", "adfs.auth.form.html")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	if form, err := NewAdfsMfaFormFromString(string(content)); err == nil {
+		t.Fatalf("FAIL: the login form was parsed as the additional authentication form: %v", form)
+	}
+	page := getTestAdfsMfaPage(AdfsMfaDuo, "duo", "Incorrect code")
+	form, err := NewAdfsMfaFormFromString(page)
+	if err != nil {
+		t.Fatalf("FAIL: failed parsing the additional authentication form: %v", err)
+	}
+	if form.AuthMethod != AdfsMfaDuo || form.Fields["Context"] != "duo" || form.ErrorText != "Incorrect code" ||
+		form.Duo == nil || form.Duo.SigRequest != "TX|duoTx:APP|duoApp" || form.Duo.PostAction != "/adfs/ls/?client-request-id=1" {
+		t.Fatalf("FAIL: unexpected additional authentication form: %+v", form)
+	}
+	t.Logf("PASS: parsed the additional authentication form")
+}
+
+// getTestAdfsMfaPage returns the additional authentication page of ADFS
+// MFA adapter. The context selects the behavior of the test server.
+func getTestAdfsMfaPage(authMethod, context, errorText string) string {
+	var extra string
+	switch {
+	case authMethod == AdfsMfaDuo:
+		extra = `<iframe id="duo_iframe" data-host="%s" data-sig-request="TX|duoTx:APP|duoApp" ` +
+			`data-post-action="/adfs/ls/?client-request-id=1"></iframe>`
+	case context == "code":
+		extra = `<input id="verificationCodeInput" name="VerificationCode" type="text" value="" />`
+	}
+	return fmt.Sprintf(`<html><body><div id="error"><span id="errorText">%s</span></div>`+
+		`<form method="post" id="options" action="/adfs/ls/?client-request-id=1">%s`+
+		`<input id="authMethod" type="hidden" name="AuthMethod" value="%s"/>`+
+		`<input id="context" type="hidden" name="Context" value="%s"/>`+
+		`</form></body></html>`, errorText, extra, authMethod, context)
+}
+
+// newTestAdfsMfaServer returns the server mimicking ADFS with MFA adapters
+// and Duo. The username selects the adapter.
+func newTestAdfsMfaServer(t *testing.T) *httptest.Server {
+	samlResponse, err := ioutil.ReadFile(path.Join("../../assets/tests", "saml2.response.roles.xml"))
+	if err != nil {
+		t.Fatalf("failed reading SAML response: %v", err)
+	}
+	samlResponseForm := fmt.Sprintf(`<html><body><form method="POST" name="hiddenform" action="https://signin.aws.amazon.com/saml">`+
+		`<input type="hidden" name="SAMLResponse" value="%s" /></form></body></html>`,
+		base64.StdEncoding.EncodeToString(samlResponse))
+	var server *httptest.Server
+	pushPolls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/adfs/ls/IdpInitiatedSignOn.aspx", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><form method="post" id="loginForm" action="/adfs/ls/?client-request-id=1">`+
+			`<input id="userNameInput" name="UserName" type="email" value="" />`+
+			`<input id="passwordInput" name="Password" type="password" />`+
+			`<input id="optionForms" type="hidden" name="AuthMethod" value="FormsAuthentication"/>`+
+			`</form></body></html>`)
+	})
+	mux.HandleFunc("/adfs/ls/", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		context := r.Form.Get("Context")
+		switch r.Form.Get("AuthMethod") {
+		case "FormsAuthentication":
+			pushPolls = 0
+			switch user := strings.Split(r.Form.Get("UserName"), "@")[0]; user {
+			case "redirect":
+				http.Redirect(w, r, "/adfs/mfa/?client-request-id=1", http.StatusSeeOther)
+			case "duo":
+				fmt.Fprintf(w, getTestAdfsMfaPage(AdfsMfaDuo, user, ""), server.Listener.Addr())
+			default:
+				fmt.Fprint(w, getTestAdfsMfaPage(AdfsMfaAzure, user, ""))
+			}
+		case AdfsMfaAzure:
+			switch {
+			case context == "redirect":
+				http.Error(w, "the form was posted to the address of the previous page", http.StatusBadRequest)
+			case context == "code" && r.Form.Get("VerificationCode") == "123456":
+				fmt.Fprint(w, samlResponseForm)
+			case context == "code":
+				fmt.Fprint(w, getTestAdfsMfaPage(AdfsMfaAzure, context, "The verification code is incorrect."))
+			case context == "push" && pushPolls >= 2:
+				fmt.Fprint(w, samlResponseForm)
+			default:
+				pushPolls++
+				fmt.Fprint(w, getTestAdfsMfaPage(AdfsMfaAzure, context, ""))
+			}
+		case AdfsMfaDuo:
+			if r.Form.Get("sig_response") != "AUTH|duoCookie:APP|duoApp" {
+				fmt.Fprintf(w, getTestAdfsMfaPage(AdfsMfaDuo, context, "Duo authentication failed."), server.Listener.Addr())
+				return
+			}
+			fmt.Fprint(w, samlResponseForm)
+		default:
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+		}
+	})
+	// The adapter page reached via redirect has the action relative to
+	// its own address.
+	mux.HandleFunc("/adfs/mfa/", func(w http.ResponseWriter, r *http.Request) {
+		page := strings.Replace(getTestAdfsMfaPage(AdfsMfaAzure, "code", ""),
+			`action="/adfs/ls/?client-request-id=1"`, `action="submit?client-request-id=1"`, 1)
+		fmt.Fprint(w, strings.Replace(page, `name="Context" value="code"`, `name="Context" value="redirect"`, 1))
+	})
+	mux.HandleFunc("/adfs/mfa/submit", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Context") != "redirect" || r.Form.Get("VerificationCode") != "123456" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, samlResponseForm)
+	})
+	duoResponse := func(w http.ResponseWriter, stat string, resp map[string]string) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"stat": stat, "message": resp["message"], "response": resp})
+	}
+	mux.HandleFunc("/frame/web/v1/auth", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tx") != "TX|duoTx" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `<html><body><form><input type="hidden" name="sid" value="duoSid"></form></body></html>`)
+	})
+	mux.HandleFunc("/frame/prompt", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("sid") != "duoSid" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		switch r.Form.Get("factor") {
+		case "Duo Push":
+			duoResponse(w, "OK", map[string]string{"txid": "pushTx"})
+		case "Passcode":
+			if r.Form.Get("passcode") != "123456" {
+				duoResponse(w, "FAIL", map[string]string{"message": "Incorrect passcode. Please try again."})
+				return
+			}
+			duoResponse(w, "OK", map[string]string{"txid": "codeTx"})
+		default:
+			duoResponse(w, "FAIL", map[string]string{"message": "Unsupported factor"})
+		}
+	})
+	mux.HandleFunc("/frame/status", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		duoResponse(w, "OK", map[string]string{"result": "SUCCESS", "result_url": "/frame/status/" + r.Form.Get("txid")})
+	})
+	mux.HandleFunc("/frame/status/", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("sid") != "duoSid" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		duoResponse(w, "OK", map[string]string{"cookie": "AUTH|duoCookie"})
+	})
+	server = httptest.NewTLSServer(mux)
+	return server
+}
+
+func TestDoAdfsMfa(t *testing.T) {
+	defer func(d time.Duration) { mfaPollInterval = d }(mfaPollInterval)
+	mfaPollInterval = 10 * time.Millisecond
+	server := newTestAdfsMfaServer(t)
+	defer server.Close()
+	testFailed := 0
+	for i, test := range []struct {
+		username   string
+		method     string
+		code       string
+		timeout    time.Duration
+		shouldFail bool
+		err        string
+	}{
+		{username: "code@contoso.com", code: "123456"},
+		{username: "code@contoso.com", code: "000000", shouldFail: true, err: "The verification code is incorrect."},
+		{username: "redirect@contoso.com", code: "123456"},
+		{username: "push@contoso.com"},
+		{username: "push@contoso.com", timeout: 15 * time.Millisecond, shouldFail: true, err: "not approved within"},
+		{username: "duo@contoso.com"},
+		{username: "duo@contoso.com", method: MfaMethodTotp, code: "123456"},
+		{username: "duo@contoso.com", method: MfaMethodTotp, code: "000000", shouldFail: true, err: "Incorrect passcode"},
+	} {
+		cli := New()
+		cli.browser.Transport = server.Client().Transport
+		cli.Config.Username = test.username
+		cli.Config.Password = "secret"
+		cli.Config.Mfa.Method = test.method
+		cli.Config.Mfa.Timeout = test.timeout
+		cli.Runtime.AuthenticationURL = server.URL + "/adfs/ls/IdpInitiatedSignOn.aspx"
+		cli.mfaCodePrompt = func(prompt string) (string, error) {
+			if test.code == "" {
+				return "", fmt.Errorf("unexpected prompt: %s", prompt)
+			}
+			return test.code, nil
+		}
+		err := cli.DoAdfsAuthnRequest()
+		if err != nil {
+			if !test.shouldFail || !strings.Contains(err.Error(), test.err) {
+				t.Logf("FAIL: Test %d: user %s, method %s, unexpected error: %v", i, test.username, test.method, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: user %s, method %s, expected to fail, failed: %v", i, test.username, test.method, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: user %s, method %s, expected to fail, but passed", i, test.username, test.method)
+			testFailed++
+			continue
+		}
+		if cli.Runtime.Saml.Attributes == nil || len(cli.Runtime.Saml.Attributes.Aws.Roles) != 3 {
+			t.Logf("FAIL: Test %d: user %s, method %s, SAML assertions were not received", i, test.username, test.method)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: user %s, method %s, expected to pass, passed", i, test.username, test.method)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
@@ -1,12 +1,14 @@
 package client
 
 // This code a stripped down version of https://github.com/glucn/saml/blob/master/internal/saml/response.go.
-// It does not include verification and signing, because they are not necessary for
-// this package.
+// It does not include signing, because it is not necessary for this package.
+// The verification of signatures is in saml_signature.go.
 
 import (
 	"encoding/xml"
 	"fmt"
+	log "github.com/sirupsen/logrus"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -103,8 +105,8 @@ type SamlAssertionConditions struct {
 
 // SamlAssertionAudienceRestriction is TBD.
 type SamlAssertionAudienceRestriction struct {
-	XMLName  xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion AudienceRestriction"`
-	Audience string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Audience"`
+	XMLName   xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion AudienceRestriction"`
+	Audiences []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Audience"`
 }
 
 // SamlAssertionAuthnStatement is TBD.
@@ -148,15 +150,19 @@ type SamlAssertionAttributeValue struct {
 type SamlResponseData struct {
 	Aws struct {
 		Roles                   []*AwsRole
+		RoleErrors              []*AwsRoleParseError
 		SessionName             string
 		SessionDuration         int
 		SessionEndTimestamp     time.Time
 		SessionStartTimestamp   time.Time
 		AuthenticateByTimestamp time.Time
 	}
-	Issuer  string
-	Success bool
-	Claims  []*SamlClaim
+	Issuer     string
+	Success    bool
+	StatusCode string
+	Recipient  string
+	Audiences  []string
+	Claims     []*SamlClaim
 }
 
 // SamlClaim is TBD.
@@ -172,32 +178,104 @@ type AwsRole struct {
 	Name                string
 	RoleARN             string
 	IdentityProviderARN string
+	Partition           string
 	ProfileName         string
 	DefaultRegion       string
+	SessionDuration     time.Duration
+	Output              string
+	Config              map[string]string
 }
 
+// AwsRoleParseError is the error of parsing a single value of AWS Role
+// SAML attribute.
+type AwsRoleParseError struct {
+	Value string
+	Err   error
+}
+
+func (e *AwsRoleParseError) Error() string {
+	return fmt.Sprintf("Failed to parse SAML Role, value: %s, error: %s", e.Value, e.Err)
+}
+
+// awsArn is the structure holding the components of IAM ARN, i.e.
+// arn:<partition>:iam::<account_id>:<resource_type>/<resource_name>.
+type awsArn struct {
+	Partition    string
+	AccountID    string
+	ResourceType string
+	ResourceName string
+}
+
+var awsAccountIDRegex = regexp.MustCompile(`^\d{12}$`)
+
+// parseAwsArn parses and validates IAM ARN of a role or a SAML provider.
+func parseAwsArn(s string) (*awsArn, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "iam" || parts[3] != "" {
+		return nil, fmt.Errorf("%s does not match expected format: arn:<partition>:iam::<account_id>:<role|saml-provider>/<name>", s)
+	}
+	if _, err := GetAwsPartition(parts[1]); err != nil {
+		return nil, fmt.Errorf("%s: %s", s, err)
+	}
+	if !awsAccountIDRegex.MatchString(parts[4]) {
+		return nil, fmt.Errorf("%s has invalid account ID: %s", s, parts[4])
+	}
+	i := strings.Index(parts[5], "/")
+	if i < 1 || strings.HasSuffix(parts[5], "/") {
+		return nil, fmt.Errorf("%s has no resource name: %s", s, parts[5])
+	}
+	a := &awsArn{
+		Partition:    parts[1],
+		AccountID:    parts[4],
+		ResourceType: parts[5][:i],
+		ResourceName: parts[5][strings.LastIndex(parts[5], "/")+1:],
+	}
+	return a, nil
+}
+
+// ParseAwsRole parses the value of AWS Role SAML attribute. The value
+// consists of a role ARN and a SAML provider ARN separated by a comma,
+// in any order.
 func ParseAwsRole(s string) (*AwsRole, error) {
 	r := AwsRole{}
 	r.Raw = s
-	parts := strings.Split(s, ",")
+	parts := strings.Split(strings.TrimSpace(s), ",")
 	if len(parts) != 2 {
 		return &r, fmt.Errorf("the passed value is expected to have two ARNs, but it has %d", len(parts))
 	}
-	if !strings.HasPrefix(parts[1], "arn:aws:iam::") {
-		return &r, fmt.Errorf("the passed Role ARN has no 'arn:aws:iam::' prefix, %s", parts[1])
+	var roleArn, providerArn *awsArn
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		arn, err := parseAwsArn(part)
+		if err != nil {
+			return &r, fmt.Errorf("the passed ARN is invalid: %s", err)
+		}
+		switch arn.ResourceType {
+		case "role":
+			if roleArn != nil {
+				return &r, fmt.Errorf("the passed value has two Role ARNs")
+			}
+			roleArn = arn
+			r.RoleARN = part
+		case "saml-provider":
+			if providerArn != nil {
+				return &r, fmt.Errorf("the passed value has two Identity Provider ARNs")
+			}
+			providerArn = arn
+			r.IdentityProviderARN = part
+		default:
+			return &r, fmt.Errorf("the passed ARN is neither role nor saml-provider: %s", part)
+		}
 	}
-	r.IdentityProviderARN = parts[0]
-	r.RoleARN = parts[1]
-	roleParts := strings.Split(r.RoleARN, ":")
-	if len(roleParts) != 6 {
-		return &r, fmt.Errorf("the passed Role ARN does not match expected format %d parts: arn:aws:iam::<account_id>:role/<role_name>", len(roleParts))
+	if roleArn == nil || providerArn == nil {
+		return &r, fmt.Errorf("the passed value is expected to have Role ARN and Identity Provider ARN")
 	}
-	r.AccountID = roleParts[4]
-	roleNameParts := strings.Split(roleParts[5], "/")
-	if len(roleNameParts) < 2 {
-		return &r, fmt.Errorf("the passed Role name does not match expected format: role/*<role_name>")
+	if roleArn.Partition != providerArn.Partition {
+		return &r, fmt.Errorf("the passed Role ARN and Identity Provider ARN are in different partitions: %s, %s", r.RoleARN, r.IdentityProviderARN)
 	}
-	r.Name = roleNameParts[len(roleNameParts)-1]
+	r.Partition = roleArn.Partition
+	r.AccountID = roleArn.AccountID
+	r.Name = roleArn.ResourceName
 	return &r, nil
 }
 
@@ -234,21 +312,29 @@ func (r *SamlResponse) GetAttributes() (*SamlResponseData, error) {
 			}
 		case AwsRoleAttribute:
 			for _, entry := range attr.Values {
-				if entry.Value == "" {
+				if strings.TrimSpace(entry.Value) == "" {
 					continue
 				}
 				role, err := ParseAwsRole(entry.Value)
 				if err != nil {
-					return nil, fmt.Errorf("Failed to parse SAML Role, value: %s, error %s", entry.Value, err)
+					// A malformed value must not hide the other roles.
+					roleErr := &AwsRoleParseError{Value: entry.Value, Err: err}
+					log.Warn(roleErr)
+					resp.Aws.RoleErrors = append(resp.Aws.RoleErrors, roleErr)
+					continue
 				}
 				resp.Aws.Roles = append(resp.Aws.Roles, role)
-				resp.Claims = append(resp.Claims, ParseSamlResponseClaim(attr.Name, attr.Values[0].Value))
+				resp.Claims = append(resp.Claims, ParseSamlResponseClaim(attr.Name, entry.Value))
 			}
 		default:
 			resp.Claims = append(resp.Claims, ParseSamlResponseClaim(attr.Name, attr.Values[0].Value))
 		}
 	}
 	if len(resp.Aws.Roles) == 0 {
+		if len(resp.Aws.RoleErrors) > 0 {
+			return nil, fmt.Errorf("AWS Roles not found: %s, %d values failed to parse, first error: %s",
+				AwsRoleAttribute, len(resp.Aws.RoleErrors), resp.Aws.RoleErrors[0])
+		}
 		return nil, fmt.Errorf("AWS Roles not found: %s", AwsRoleAttribute)
 	}
 	if resp.Aws.SessionName == "" {
@@ -259,5 +345,8 @@ func (r *SamlResponse) GetAttributes() (*SamlResponseData, error) {
 	resp.Aws.SessionEndTimestamp = r.Assertion.Conditions.NotOnOrAfter
 	resp.Issuer = r.Assertion.Issuer
 	resp.Success = strings.Contains(r.Status.StatusCode.Value, "status:Success")
+	resp.StatusCode = r.Status.StatusCode.Value
+	resp.Recipient = r.Assertion.Subject.Confirmation.Data.Recipient
+	resp.Audiences = r.Assertion.Conditions.AudienceRestriction.Audiences
 	return resp, nil
 }
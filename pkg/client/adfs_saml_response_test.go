@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestParseAwsRole(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		input       string
+		roleARN     string
+		providerARN string
+		accountID   string
+		name        string
+		shouldErr   bool
+	}{
+		{
+			input:       "arn:aws:iam::795318967487:saml-provider/ADFS,arn:aws:iam::795318967487:role/Administrator",
+			roleARN:     "arn:aws:iam::795318967487:role/Administrator",
+			providerARN: "arn:aws:iam::795318967487:saml-provider/ADFS",
+			accountID:   "795318967487",
+			name:        "Administrator",
+		},
+		{
+			input:       "arn:aws:iam::795318967487:role/Administrator,arn:aws:iam::795318967487:saml-provider/ADFS",
+			roleARN:     "arn:aws:iam::795318967487:role/Administrator",
+			providerARN: "arn:aws:iam::795318967487:saml-provider/ADFS",
+			accountID:   "795318967487",
+			name:        "Administrator",
+		},
+		{
+			input:       "\n  arn:aws:iam::795318967487:role/team/ReadOnly , arn:aws:iam::795318967487:saml-provider/ADFS \n",
+			roleARN:     "arn:aws:iam::795318967487:role/team/ReadOnly",
+			providerARN: "arn:aws:iam::795318967487:saml-provider/ADFS",
+			accountID:   "795318967487",
+			name:        "ReadOnly",
+		},
+		{
+			input:     "arn:aws:iam::795318967487:role/Administrator",
+			shouldErr: true,
+		},
+		{
+			input:     "arn:aws:iam::795318967487:role/Administrator,arn:aws:iam::795318967487:role/ReadOnly",
+			shouldErr: true,
+		},
+		{
+			input:     "arn:aws:iam::795318967487:saml-provider/ADFS,arn:aws:iam::795318967487:user/Administrator",
+			shouldErr: true,
+		},
+		{
+			input:     "arn:aws:iam::7953189674:saml-provider/ADFS,arn:aws:iam::7953189674:role/Administrator",
+			shouldErr: true,
+		},
+		{
+			input:     "arn:aws:iam::795318967487:saml-provider/ADFS,arn:aws:iam::795318967487:role/",
+			shouldErr: true,
+		},
+	} {
+		role, err := ParseAwsRole(test.input)
+		if err != nil {
+			if !test.shouldErr {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input '%s', expected to throw error, threw: %v", i, test.input, err)
+			continue
+		}
+		if test.shouldErr {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw error, but passed: %v", i, test.input, role)
+			testFailed++
+			continue
+		}
+		if role.RoleARN != test.roleARN || role.IdentityProviderARN != test.providerARN {
+			t.Logf("FAIL: Test %d: input '%s', ARN mismatch %s, %s (expected) vs %s, %s",
+				i, test.input, test.roleARN, test.providerARN, role.RoleARN, role.IdentityProviderARN)
+			testFailed++
+			continue
+		}
+		if role.AccountID != test.accountID || role.Name != test.name {
+			t.Logf("FAIL: Test %d: input '%s', role mismatch %s/%s (expected) vs %s/%s",
+				i, test.input, test.accountID, test.name, role.AccountID, role.Name)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestGetAttributesRoles(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		input      string
+		roles      int
+		roleErrors int
+	}{
+		{input: "saml2.response.xml", roles: 5, roleErrors: 0},
+		{input: "saml2.response.roles.xml", roles: 3, roleErrors: 2},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		var r SamlResponse
+		if err := xml.Unmarshal(content, &r); err != nil {
+			t.Logf("FAIL: Test %d: input '%s', failed to unmarshal: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		attrs, err := r.GetAttributes()
+		if err != nil {
+			t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		if len(attrs.Aws.Roles) != test.roles || len(attrs.Aws.RoleErrors) != test.roleErrors {
+			t.Logf("FAIL: Test %d: input '%s', mismatch %d roles and %d errors (expected) vs %d roles and %d errors",
+				i, test.input, test.roles, test.roleErrors, len(attrs.Aws.Roles), len(attrs.Aws.RoleErrors))
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
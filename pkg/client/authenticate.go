@@ -1,10 +1,11 @@
 package client
 
 import (
+	"encoding/base64"
+	"encoding/xml"
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"io/ioutil"
-	"net/http"
+	"time"
 )
 
 type SamlAuthRequestParams struct {
@@ -14,8 +15,15 @@ type SamlAuthRequestParams struct {
 }
 
 // GetSamlAssertions requests SAML assertions either from
-// ADFS instance, Azure AD, or local file.
+// ADFS instance, Azure AD, or local file. When the cache is enabled,
+// the cached assertions are reused until they expire.
 func (c *Client) GetSamlAssertions() error {
+	if c.GetCachedSamlAssertions() {
+		return nil
+	}
+	if err := c.LoadCookieJar(); err != nil {
+		log.Warnf("Discarding IdP session: %s", err)
+	}
 	if err := c.GetAuthenticationURL(); err != nil {
 		return err
 	}
@@ -29,28 +37,58 @@ func (c *Client) GetSamlAssertions() error {
 			return err
 		}
 	}
+	if err := c.VerifySamlSignature(); err != nil {
+		return err
+	}
 	if err := c.OutputCurrentState(); err != nil {
 		return err
 	}
 	if err := c.IsSamlAssertionValid(); err != nil {
 		return err
 	}
+	if err := c.SaveCookieJar(); err != nil {
+		log.Warnf("Failed to save IdP session: %s", err)
+	}
+	if c.isSamlAssertionCacheEnabled() {
+		if err := c.CacheSamlAssertions(); err != nil {
+			log.Warnf("Failed to cache SAML assertions: %s", err)
+		}
+	}
 	if err := c.IsAwsRoleAvailable(); err == nil {
 		return err
 	}
 	return nil
 }
 
+// IsSamlAssertionValid checks whether SAML Assertion is successful, valid
+// at the moment, and intended for AWS.
 func (c *Client) IsSamlAssertionValid() error {
-	// TODO: check the fields
-	// resp.Aws.AuthenticateByTimestamp = r.Assertion.Subject.Confirmation.Data.NotOnOrAfter
-	// resp.Aws.SessionStartTimestamp = r.Assertion.Conditions.NotBefore
-	// if onOrAfter {
-	//    return fmt.Errorf("SAML Assertion is not valid due to NotOnOrAfter for the assertion being met: %s",  c.Runtime.Saml.Attributes.Aws.Conditions.NotOnOrAfter);
-	// }
-	// if notBefore {
-	//    return fmt.Errorf("SAML Assertion is invalid due to NotBefore for the session being met: %s",  c.Runtime.Saml.Attributes.Aws.Conditions.NotBefore)
-	// }
+	clockSkew := c.Config.Saml.ClockSkew
+	if clockSkew == 0 {
+		clockSkew = DefaultSamlClockSkew
+	}
+	opts := &SamlValidationOptions{
+		Now:       time.Now(),
+		ClockSkew: clockSkew,
+	}
+	// The assertion is expected to be addressed to the partition of the
+	// issued roles.
+	partitions := []*AwsPartition{c.GetDefaultAwsPartition()}
+	for _, role := range c.Runtime.Saml.Attributes.Aws.Roles {
+		if p, err := GetAwsPartition(role.Partition); err == nil {
+			partitions = append(partitions, p)
+		}
+	}
+	for _, p := range partitions {
+		if !containsString(opts.Recipients, p.SamlConsumerURL) {
+			opts.Recipients = append(opts.Recipients, p.SamlConsumerURL)
+			opts.Audiences = append(opts.Audiences, p.RelyingPartyURN)
+		}
+	}
+	if err := c.Runtime.Saml.Attributes.Validate(opts); err != nil {
+		return err
+	}
+	log.Debugf("SAML Assertion is valid, clock skew: %s", clockSkew)
 	return nil
 }
 
@@ -64,8 +102,19 @@ func (c *Client) GetRequestedAwsRoles() []*AwsRole {
 			if configRole.Name != role.Name {
 				continue
 			}
+			if c.isAwsRoleCached(configRole) {
+				break
+			}
 			role.ProfileName = configRole.ProfileName
 			role.DefaultRegion = configRole.DefaultRegion
+			role.SessionDuration = configRole.Duration
+			role.Output = configRole.Output
+			role.Config = configRole.Config
+			if role.DefaultRegion == "" {
+				if p, err := GetAwsPartition(role.Partition); err == nil {
+					role.DefaultRegion = p.DefaultRegion
+				}
+			}
 			roles = append(roles, role)
 			break
 		}
@@ -107,6 +156,9 @@ func (c *Client) OutputCurrentState() error {
 	for _, role := range c.Runtime.Saml.Attributes.Aws.Roles {
 		log.Debugf("  - %s on account ID %s", role.Name, role.AccountID)
 	}
+	for _, roleErr := range c.Runtime.Saml.Attributes.Aws.RoleErrors {
+		log.Debugf("  - skipped: %s", roleErr)
+	}
 	if c.Runtime.Saml.Attributes.Aws.SessionDuration > 0 {
 		log.Debugf("ADFS authorized AWS session duration: %d", c.Runtime.Saml.Attributes.Aws.SessionDuration)
 	}
@@ -129,21 +181,33 @@ func (c *Client) OutputCurrentState() error {
 
 // AuthenticateWithAdfs authenticates to ADFS and receives SAML assertions back.
 func (c *Client) AuthenticateWithAdfs() error {
-	formData, err := c.GetAdfsAuthenticationRequestBody()
+	return c.DoAdfsAuthnRequest()
+}
+
+// DecodeSamlResponse decodes base64-encoded SAMLResponse received from an
+// IdP and parses the SAML assertions in it. The src describes the origin
+// of the SAMLResponse for error reporting.
+func (c *Client) DecodeSamlResponse(encoded, src string) error {
+	var err error
+	c.Runtime.Saml.Assertions = &SamlResponseAssertions{}
+	c.Runtime.Saml.Assertions.Raw, err = base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return err
+		return fmt.Errorf("Failed to decode SAMLResponse in %s: %s", src, err)
 	}
-	resp, err := http.PostForm(c.Runtime.AuthenticationURL, formData)
-	if err != nil {
-		return fmt.Errorf("Error authenticating @ %s: %s", c.Runtime.AuthenticationURL, err)
+	c.Runtime.Saml.Assertions.Plain = string(c.Runtime.Saml.Assertions.Raw[:])
+	// The response of the previous authentication is discarded, because
+	// the unmarshalling appends the attributes to it.
+	c.Runtime.Saml.Response = SamlResponse{}
+	if err := xml.Unmarshal(c.Runtime.Saml.Assertions.Raw, &c.Runtime.Saml.Response); err != nil {
+		return fmt.Errorf("Failed to unmarshal SAMLResponse in %s: %s", src, err)
+	}
+	if c.Runtime.Saml.Response.Assertion.AttributeStatement == nil {
+		return fmt.Errorf("SAMLResponse in %s does not contain attribute statements: %v", src, c.Runtime.Saml.Response.Assertion)
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	c.Runtime.Saml.Attributes, err = c.Runtime.Saml.Response.GetAttributes()
 	if err != nil {
-		return fmt.Errorf("Error reading response data from %s: %s", c.Runtime.AuthenticationURL, err)
+		return fmt.Errorf("Failed to get attributes from SAMLResponse in %s: %s", src, err)
 	}
-	responseBody := string(body[:])
-	log.Debugf("ADFS responded with: %s", responseBody)
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
@@ -8,12 +9,16 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // AssumeRoleWithSaml makes AWS API call to STS service and asks for
 //temporary credentials.
 func (c *Client) AssumeRoleWithSaml() error {
 	roles := c.GetRequestedAwsRoles()
+	if len(roles) == 0 && len(c.Runtime.CachedRoles) > 0 {
+		return nil
+	}
 	if len(roles) == 0 {
 		return fmt.Errorf("The available AWS roles do no match any of the requested AWS roles")
 	}
@@ -27,11 +32,20 @@ func (c *Client) AssumeRoleWithSaml() error {
 		keyValuePairs.Add("RoleArn", role.RoleARN)
 		keyValuePairs.Add("PrincipalArn", role.IdentityProviderARN)
 		keyValuePairs.Add("SAMLAssertion", encodedAssertions)
+		duration := c.GetAwsSessionDuration(role)
+		if duration > 0 {
+			keyValuePairs.Add("DurationSeconds", strconv.Itoa(int(duration.Seconds())))
+		}
 		postData := strings.NewReader(keyValuePairs.Encode())
-		log.Debugf("AWS STS Authentication URL: %s", c.Config.Aws.AuthenticationURL)
-		req, err := http.NewRequest("POST", c.Config.Aws.AuthenticationURL, postData)
+		stsURL, err := c.GetAwsStsURL(role)
+		if err != nil {
+			c.addAwsRoleError(role, fmt.Errorf("Error assuming AWS role %s: %s", role.RoleARN, err))
+			continue
+		}
+		log.Debugf("AWS STS Authentication URL: %s", stsURL)
+		req, err := http.NewRequest("POST", stsURL, postData)
 		if err != nil {
-			log.Errorf("Error creating http post when assuming AWS role: %s", err)
+			c.addAwsRoleError(role, fmt.Errorf("Error creating http post when assuming AWS role: %s", err))
 			continue
 		}
 		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
@@ -39,30 +53,93 @@ func (c *Client) AssumeRoleWithSaml() error {
 		req.Header.Add("Accept", "application/json")
 		resp, err := c.browser.Do(req)
 		if err != nil {
-			log.Errorf("Error authenticating @ %s when assuming AWS role: %s", c.Config.Aws.AuthenticationURL, err)
+			c.addAwsRoleError(role, fmt.Errorf("Error authenticating @ %s when assuming AWS role: %s", stsURL, err))
 			continue
 		}
 		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			log.Errorf("Error reading response data from %s when assuming AWS role: %s", c.Config.Aws.AuthenticationURL, err)
+			c.addAwsRoleError(role, fmt.Errorf("Error reading response data from %s when assuming AWS role: %s", stsURL, err))
 			continue
 		}
 		awsStsResponse, err := NewAwsStsResponseFromBytes(body)
 		if err != nil {
-			log.Errorf("Error decoding STS response when assuming AWS role: %s", err)
+			var stsErr *AwsStsError
+			if duration > 0 && errors.As(err, &stsErr) && stsErr.IsSessionDurationError() {
+				c.addAwsRoleError(role, fmt.Errorf("AWS STS rejected session duration %s for AWS role %s, "+
+					"the MaxSessionDuration of the role is likely lower, reduce 'duration' of the role: %s",
+					duration, role.RoleARN, err))
+				continue
+			}
+			c.addAwsRoleError(role, fmt.Errorf("Error decoding STS response when assuming AWS role: %s", err))
 			continue
 		}
 		log.Debugf("Received AWS STS Response: %v", awsStsResponse)
 		awsCredentials, err := NewAwsCredentialsFromStsResponse(awsStsResponse)
 		if err != nil {
-			log.Errorf("Error creating AWS credentials when assuming AWS role: %s", err)
+			c.addAwsRoleError(role, fmt.Errorf("Error creating AWS credentials when assuming AWS role: %s", err))
 			continue
 		}
 		log.Debugf("The AWS Credentials are: %v", awsCredentials)
+		if !awsCredentials.Expiration.IsZero() {
+			log.Debugf("The AWS Credentials for %s expire at %s (in %s)", role.RoleARN,
+				awsCredentials.Expiration.Format(time.RFC3339), time.Until(awsCredentials.Expiration).Round(time.Second))
+		}
+		awsCredentials.RoleARN = role.RoleARN
 		awsCredentials.ProfileName = role.ProfileName
 		awsCredentials.DefaultRegion = role.DefaultRegion
+		awsCredentials.Output = role.Output
+		awsCredentials.Config = role.Config
 		c.Aws.Credentials = append(c.Aws.Credentials, awsCredentials)
+		if c.Config.Cache.Enabled {
+			if err := c.CacheAwsCredentials(role, awsCredentials); err != nil {
+				log.Warnf("Failed to cache credentials for AWS role %s: %s", role.RoleARN, err)
+			}
+		}
 	}
 	return nil
 }
+
+// addAwsRoleError logs and records the failure to assume an AWS role. The
+// remaining roles are still assumed.
+func (c *Client) addAwsRoleError(role *AwsRole, err error) {
+	log.Error(err)
+	c.Aws.Errors = append(c.Aws.Errors, &AwsAssumeRoleError{
+		RoleARN:     role.RoleARN,
+		ProfileName: role.ProfileName,
+		Err:         err,
+	})
+}
+
+// GetAwsStsURL returns the STS endpoint for the partition of an AWS role.
+// The endpoint in the configuration, if any, takes precedence.
+func (c *Client) GetAwsStsURL(role *AwsRole) (string, error) {
+	if c.Config.Aws.AuthenticationURL != "" {
+		return c.Config.Aws.AuthenticationURL, nil
+	}
+	p, err := GetAwsPartition(role.Partition)
+	if err != nil {
+		return "", err
+	}
+	return p.STSURL, nil
+}
+
+// GetAwsSessionDuration returns the duration of AWS session requested for
+// an AWS role. The duration is limited by the session duration asserted by
+// IdP. It returns zero when the role has no duration, i.e. STS default.
+func (c *Client) GetAwsSessionDuration(role *AwsRole) time.Duration {
+	duration := role.SessionDuration
+	if duration == 0 {
+		return 0
+	}
+	if c.Runtime.Saml.Attributes == nil || c.Runtime.Saml.Attributes.Aws.SessionDuration == 0 {
+		return duration
+	}
+	maxDuration := time.Duration(c.Runtime.Saml.Attributes.Aws.SessionDuration) * time.Second
+	if duration > maxDuration {
+		log.Warnf("The requested session duration %s for AWS role %s exceeds the maximum asserted by IdP, using %s",
+			duration, role.RoleARN, maxDuration)
+		duration = maxDuration
+	}
+	return duration
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestGetAwsSessionDuration(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		requested   time.Duration
+		idpDuration int
+		exp         time.Duration
+	}{
+		{requested: 0, idpDuration: 28800, exp: 0},
+		{requested: 4 * time.Hour, idpDuration: 28800, exp: 4 * time.Hour},
+		{requested: 12 * time.Hour, idpDuration: 28800, exp: 8 * time.Hour},
+		{requested: 12 * time.Hour, idpDuration: 0, exp: 12 * time.Hour},
+	} {
+		cli := New()
+		cli.Runtime.Saml.Attributes = &SamlResponseData{}
+		cli.Runtime.Saml.Attributes.Aws.SessionDuration = test.idpDuration
+		role := &AwsRole{RoleARN: "arn:aws:iam::795318967487:role/Administrator", SessionDuration: test.requested}
+		if d := cli.GetAwsSessionDuration(role); d != test.exp {
+			t.Logf("FAIL: Test %d: mismatch %s (expected) vs %s", i, test.exp, d)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: requested %s, IdP maximum %ds, expected to pass, passed", i, test.requested, test.idpDuration)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestAwsStsSessionDurationError(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		input           string
+		isDurationError bool
+	}{
+		{input: "aws.sts.response.error.duration.json", isDurationError: true},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		_, err = NewAwsStsResponseFromBytes(content)
+		var stsErr *AwsStsError
+		if !errors.As(err, &stsErr) {
+			t.Logf("FAIL: Test %d: input '%s', expected AWS STS error, but got: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		if stsErr.IsSessionDurationError() != test.isDurationError {
+			t.Logf("FAIL: Test %d: input '%s', duration error mismatch %t (expected) vs %t",
+				i, test.input, test.isDurationError, stsErr.IsSessionDurationError())
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
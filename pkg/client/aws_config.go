@@ -1,17 +1,74 @@
 package client
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MinAwsSessionDuration is the shortest session AWS STS issues.
+	MinAwsSessionDuration = 15 * time.Minute
+	// MaxAwsSessionDuration is the longest session AWS STS issues.
+	MaxAwsSessionDuration = 12 * time.Hour
+)
+
 type AwsConfigurationRole struct {
-	AccountID     string `xml:"account_id,attr" json:"account_id" yaml:"account_id"`
-	Name          string `xml:"role,attr" json:"role" yaml:"role"`
-	ProfileName   string `xml:"profile_name,attr" json:"profile_name" yaml:"profile_name"`
-	DefaultRegion string `xml:"region,attr" json:"region" yaml:"region"`
+	AccountID     string        `xml:"account_id,attr" json:"account_id" yaml:"account_id"`
+	Name          string        `xml:"role,attr" json:"role" yaml:"role"`
+	ProfileName   string        `xml:"profile_name,attr" json:"profile_name" yaml:"profile_name"`
+	DefaultRegion string        `xml:"region,attr" json:"region" yaml:"region"`
+	Duration      time.Duration `xml:"duration,attr" json:"duration" yaml:"duration"`
+	Output        string        `xml:"output,attr" json:"output" yaml:"output"`
+	// Config is the extra keys of the profile in `.aws/config` file.
+	Config map[string]string `xml:"-" json:"config" yaml:"config"`
 }
 
 type AwsConfiguration struct {
 	Roles             []*AwsConfigurationRole `xml:"roles,attr" json:"roles" yaml:"roles"`
 	AuthenticationURL string                  `xml:"url,attr" json:"url" yaml:"url"`
+	Partition         string                  `xml:"partition,attr" json:"partition" yaml:"partition"`
+	// AccountAliases are the names of AWS accounts by account ID.
+	AccountAliases map[string]string `xml:"-" json:"account_aliases" yaml:"account_aliases"`
 }
 
 type Aws struct {
 	Credentials []*AwsCredentials
+	// Errors are the failures to assume the requested roles.
+	Errors []*AwsAssumeRoleError
+}
+
+// AwsAssumeRoleError is the failure to assume an AWS role.
+type AwsAssumeRoleError struct {
+	RoleARN     string
+	ProfileName string
+	Err         error
+}
+
+func (e *AwsAssumeRoleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AwsAssumeRoleError) Unwrap() error {
+	return e.Err
+}
+
+// ParseAwsSessionDuration parses the duration of AWS session. The duration
+// is either the number of seconds, e.g. 3600, or Go duration, e.g. 1h.
+func ParseAwsSessionDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var d time.Duration
+	if i, err := strconv.Atoi(s); err == nil {
+		d = time.Duration(i) * time.Second
+	} else {
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid AWS session duration: %s", s)
+		}
+	}
+	if d < MinAwsSessionDuration || d > MaxAwsSessionDuration {
+		return 0, fmt.Errorf("AWS session duration %s is not between %s and %s", d, MinAwsSessionDuration, MaxAwsSessionDuration)
+	}
+	return d, nil
 }
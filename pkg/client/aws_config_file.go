@@ -0,0 +1,85 @@
+package client
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sort"
+)
+
+const (
+	// AwsProfileCredentialsFile is the mode of writing AWS profiles to
+	// `.aws/credentials` file only, including region.
+	AwsProfileCredentialsFile = "credentials"
+	// AwsProfileConfigFile is the mode of writing AWS profiles to
+	// `.aws/config` file only, i.e. region, output, and extra keys.
+	AwsProfileConfigFile = "config"
+	// AwsProfileBothFiles is the mode of writing AWS keys to
+	// `.aws/credentials` file and the rest of the profile to `.aws/config`
+	// file.
+	AwsProfileBothFiles = "both"
+)
+
+// GetAwsProfileWriteModes returns the supported modes of writing AWS profiles.
+func GetAwsProfileWriteModes() []string {
+	return []string{AwsProfileCredentialsFile, AwsProfileConfigFile, AwsProfileBothFiles}
+}
+
+// GetConfigSectionName returns the name of the section of the profile in
+// `.aws/config` file. Unlike the credentials file, the config file prefixes
+// the names of the profiles, other than default, with `profile`.
+func (c *AwsCredentials) GetConfigSectionName() string {
+	if c.ProfileName == "default" {
+		return c.ProfileName
+	}
+	return "profile " + c.ProfileName
+}
+
+// WriteConfigFile writes the settings of the profile to a file i.e.
+// `.aws/config`. It adds `region`, `output`, and the extra keys from the
+// configuration of the role to `[profile <name>]` section. If the profile
+// exists, it updates the keys of the profile in place and keeps the rest
+// of the file intact.
+func (c *AwsCredentials) WriteConfigFile(fp string) error {
+	fp = ExpandFilePath(fp)
+	name := c.GetConfigSectionName()
+	err := UpdateIniFile(fp, func(f *IniFile) error {
+		section := f.AddSection(name)
+		if c.DefaultRegion != "" {
+			section.Set("region", c.DefaultRegion)
+		}
+		if c.Output != "" {
+			section.Set("output", c.Output)
+		}
+		keys := []string{}
+		for k := range c.Config {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			section.Set(k, c.Config[k])
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Erred writing %s profile to %s: %s", c.ProfileName, fp, err)
+	}
+	log.Infof("Updated [%s] aws config profile in %s", name, fp)
+	return nil
+}
+
+// WriteProfileFiles writes the profile to `.aws/credentials` and/or
+// `.aws/config` files, depending on the mode.
+func (c *AwsCredentials) WriteProfileFiles(credFilePath, configFilePath, mode string) error {
+	switch mode {
+	case AwsProfileCredentialsFile:
+		return c.writeCredentialsFile(credFilePath, true)
+	case AwsProfileConfigFile:
+		return c.WriteConfigFile(configFilePath)
+	case AwsProfileBothFiles, "":
+		if err := c.writeCredentialsFile(credFilePath, false); err != nil {
+			return err
+		}
+		return c.WriteConfigFile(configFilePath)
+	}
+	return fmt.Errorf("unsupported mode of writing AWS profiles: %s", mode)
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestWriteProfileFiles(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		mode        string
+		profile     string
+		config      string
+		credentials string
+		expConfig   string
+		expCreds    string
+	}{
+		{
+			mode:    AwsProfileBothFiles,
+			profile: "prod",
+			config: "[default]\nregion=us-east-1\n\n" +
+				"# production account\n[profile prod]\nregion=us-west-1\ncli_pager=\n",
+			credentials: "[prod]\nregion=us-west-1\naws_access_key_id=OLD\n",
+			expConfig: "[default]\nregion=us-east-1\n\n" +
+				"# production account\n[profile prod]\nregion=us-east-2\ncli_pager=\noutput=json\nmfa_serial=none\n",
+			expCreds: "[prod]\naws_access_key_id=KEY\naws_secret_access_key=SECRET\naws_session_token=TOKEN\n",
+		},
+		{
+			mode:      AwsProfileConfigFile,
+			profile:   "default",
+			expConfig: "[default]\nregion=us-east-2\noutput=json\nmfa_serial=none\n",
+		},
+		{
+			mode:     AwsProfileCredentialsFile,
+			profile:  "dev",
+			expCreds: "[dev]\nregion=us-east-2\naws_access_key_id=KEY\naws_secret_access_key=SECRET\naws_session_token=TOKEN\n",
+		},
+	} {
+		tmpDir, err := ioutil.TempDir("", "ggk-profile")
+		if err != nil {
+			t.Fatalf("failed creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		configFilePath := path.Join(tmpDir, "config")
+		credFilePath := path.Join(tmpDir, "credentials")
+		for fp, content := range map[string]string{configFilePath: test.config, credFilePath: test.credentials} {
+			if content == "" {
+				continue
+			}
+			if err := ioutil.WriteFile(fp, []byte(content), 0600); err != nil {
+				t.Fatalf("failed writing %s: %v", fp, err)
+			}
+		}
+		creds := &AwsCredentials{
+			AccessKeyId:     "KEY",
+			SecretAccessKey: "SECRET",
+			SessionToken:    "TOKEN",
+			ProfileName:     test.profile,
+			DefaultRegion:   "us-east-2",
+			Output:          "json",
+			Config:          map[string]string{"mfa_serial": "none"},
+		}
+		if err := creds.WriteProfileFiles(credFilePath, configFilePath, test.mode); err != nil {
+			t.Logf("FAIL: Test %d: mode '%s', expected to pass, but threw error: %v", i, test.mode, err)
+			testFailed++
+			continue
+		}
+		isMismatch := false
+		for fp, exp := range map[string]string{configFilePath: test.expConfig, credFilePath: test.expCreds} {
+			b, _ := ioutil.ReadFile(fp)
+			if string(b) != exp {
+				t.Logf("FAIL: Test %d: mode '%s', %s mismatch\n%s\n(expected) vs\n%s", i, test.mode, fp, exp, b)
+				isMismatch = true
+			}
+		}
+		if isMismatch {
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: mode '%s', expected to pass, passed", i, test.mode)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
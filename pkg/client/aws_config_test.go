@@ -0,0 +1,47 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAwsSessionDuration(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		input     string
+		exp       time.Duration
+		shouldErr bool
+	}{
+		{input: "3600", exp: time.Hour},
+		{input: "8h", exp: 8 * time.Hour},
+		{input: " 90m ", exp: 90 * time.Minute},
+		{input: "600", shouldErr: true},
+		{input: "13h", shouldErr: true},
+		{input: "one hour", shouldErr: true},
+	} {
+		d, err := ParseAwsSessionDuration(test.input)
+		if err != nil {
+			if !test.shouldErr {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input '%s', expected to throw error, threw: %v", i, test.input, err)
+			continue
+		}
+		if test.shouldErr {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw error, but passed: %s", i, test.input, d)
+			testFailed++
+			continue
+		}
+		if d != test.exp {
+			t.Logf("FAIL: Test %d: input '%s', mismatch %s (expected) vs %s", i, test.input, test.exp, d)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
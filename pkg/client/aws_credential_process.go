@@ -0,0 +1,40 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AwsCredentialProcessOutput is the document AWS SDKs expect on stdout of
+// the command referenced by `credential_process` setting of a profile.
+// See https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-sourcing-external.html
+type AwsCredentialProcessOutput struct {
+	Version         int    `json:"Version"`
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+// GetCredentialProcessOutput returns the credentials in the format of
+// `credential_process` output. The SDKs refresh the credentials by running
+// the command again when the credentials are about to expire.
+func (c *AwsCredentials) GetCredentialProcessOutput() ([]byte, error) {
+	if err := c.IsValid(); err != nil {
+		return nil, err
+	}
+	out := &AwsCredentialProcessOutput{
+		Version:         1,
+		AccessKeyId:     c.AccessKeyId,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+	}
+	if !c.Expiration.IsZero() {
+		out.Expiration = c.Expiration.UTC().Format(time.RFC3339)
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
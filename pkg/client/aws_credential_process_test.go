@@ -0,0 +1,61 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestGetCredentialProcessOutput(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		input      string
+		expiration string
+	}{
+		{input: "aws.sts.response.1.json", expiration: "2019-07-17T12:18:00Z"},
+		{input: "aws.sts.response.3.json", expiration: "2019-07-17T12:18:00Z"},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		resp, err := NewAwsStsResponseFromBytes(content)
+		if err != nil {
+			t.Logf("FAIL: Test %d: input '%s', failed parsing: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		creds, err := NewAwsCredentialsFromStsResponse(resp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: input '%s', failed creating credentials: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		b, err := creds.GetCredentialProcessOutput()
+		if err != nil {
+			t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		out := &AwsCredentialProcessOutput{}
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Logf("FAIL: Test %d: input '%s', output is not JSON: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		if out.Version != 1 || out.AccessKeyId != creds.AccessKeyId || out.Expiration != test.expiration {
+			t.Logf("FAIL: Test %d: input '%s', unexpected output: %s", i, test.input, b)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
@@ -1,13 +1,9 @@
 package client
 
 import (
-	"bufio"
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"io/ioutil"
-	"os"
-	"runtime"
-	"strings"
+	"time"
 )
 
 // AwsCredentials holds raw AWS STS response.
@@ -16,8 +12,12 @@ type AwsCredentials struct {
 	AccessKeyId     string
 	SecretAccessKey string
 	SessionToken    string
+	RoleARN         string
 	ProfileName     string
 	DefaultRegion   string
+	Output          string
+	Config          map[string]string
+	Expiration      time.Time
 }
 
 // IsValid check whether the credentials contain mandatory keys.
@@ -41,6 +41,7 @@ func NewAwsCredentialsFromStsResponse(resp *AwsStsResponse) (*AwsCredentials, er
 		AccessKeyId:     resp.Credentials.AccessKeyId,
 		SecretAccessKey: resp.Credentials.SecretAccessKey,
 		SessionToken:    resp.Credentials.SessionToken,
+		Expiration:      resp.Credentials.Expiration.Time,
 	}
 	if err := a.IsValid(); err != nil {
 		return nil, err
@@ -52,99 +53,113 @@ func NewAwsCredentialsFromStsResponse(resp *AwsStsResponse) (*AwsCredentials, er
 // The function takes in a file path and a profile name. It creates a profile
 // definition with the supplied namd and adds `aws_access_key_id`,
 // `aws_secret_access_key`, and `aws_session_token` to the profile.
-// If the profile exists, it overwrites.
+// When the expiration of the credentials is known, it is recorded in
+// `x_security_token_expires` and `aws_expiration` keys.
+// If the profile exists, it updates the keys of the profile in place and
+// keeps the rest of the file, e.g. comments and other profiles, intact.
 func (c *AwsCredentials) WriteCredentialsFile(fp string) error {
+	return c.writeCredentialsFile(fp, true)
+}
+
+// writeCredentialsFile writes the credentials to a file. When the region
+// is written to `.aws/config` file, it is removed from the profile.
+func (c *AwsCredentials) writeCredentialsFile(fp string, withRegion bool) error {
 	if err := c.IsValid(); err != nil {
 		return err
 	}
 	fp = ExpandFilePath(fp)
-
-	ff := os.O_APPEND | os.O_WRONLY
-	isFileExists := true
-	if _, err := os.Stat(fp); os.IsNotExist(err) {
-		isFileExists = false
-		ff = os.O_APPEND | os.O_CREATE | os.O_WRONLY
-	}
-
-	if isFileExists {
-		var esb strings.Builder
-		isWriter := true
-		fh, err := os.Open(fp)
-		if err != nil {
-			return fmt.Errorf("Erred opening existing file %s: %s", fp, err)
-		}
-		defer fh.Close()
-		scanner := bufio.NewScanner(fh)
-		for scanner.Scan() {
-			if strings.Contains(scanner.Text(), "["+c.ProfileName+"]") {
-				// Found the reference to a section with the target
-				// profile name
-				isWriter = false
-			} else if strings.HasPrefix(scanner.Text(), "[") {
-				// Found the reference to a section not containing the
-				// target profile name
-				isWriter = true
-			}
-			if isWriter {
-				esb.WriteString(scanner.Text())
-				esb.WriteRune('\n')
-			}
+	isProfileExists := false
+	err := UpdateIniFile(fp, func(f *IniFile) error {
+		isProfileExists = f.GetSection(c.ProfileName) != nil
+		section := f.AddSection(c.ProfileName)
+		if c.Raw != nil && c.Raw.AssumedRoleUser != nil {
+			section.SetComment("Assumed Role ID", c.Raw.AssumedRoleUser.AssumedRoleId)
+			section.SetComment("Assumed Role ARN", c.Raw.AssumedRoleUser.Arn)
 		}
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("Erred reading existing file %s: %s", fp, err)
+		if withRegion {
+			section.Set("region", c.DefaultRegion)
+		} else {
+			section.Delete("region")
 		}
-		if err := ioutil.WriteFile(fp, []byte(strings.TrimLeft(esb.String(), "\n")), 0600); err != nil {
-			return err
+		section.Set("aws_access_key_id", c.AccessKeyId)
+		section.Set("aws_secret_access_key", c.SecretAccessKey)
+		section.Set("aws_session_token", c.SessionToken)
+		if c.Expiration.IsZero() {
+			section.Delete("x_security_token_expires")
+			section.Delete("aws_expiration")
+		} else {
+			expiration := c.Expiration.UTC().Format(time.RFC3339)
+			section.Set("x_security_token_expires", expiration)
+			section.Set("aws_expiration", expiration)
 		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Erred writing %s profile to %s: %s", c.ProfileName, fp, err)
 	}
+	if isProfileExists {
+		log.Infof("Updated %s aws credentials profile in %s", c.ProfileName, fp)
+	} else {
+		log.Infof("Added %s aws credentials profile to %s", c.ProfileName, fp)
+	}
+	return nil
+}
 
-	var sb strings.Builder
-	if isFileExists {
-		sb.WriteRune('\n')
+// EnvVar is an environment variable.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// GetEnvVars returns the environment variables AWS SDKs and CLI read the
+// credentials from, i.e. `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY`,
+// `AWS_SESSION_TOKEN`, the region, and the expiration, if known.
+func (c *AwsCredentials) GetEnvVars() []EnvVar {
+	vars := []EnvVar{}
+	if c.DefaultRegion != "" {
+		vars = append(vars, EnvVar{"AWS_DEFAULT_REGION", c.DefaultRegion})
+		vars = append(vars, EnvVar{"AWS_REGION", c.DefaultRegion})
 	}
-	sb.WriteString(fmt.Sprintf("[%s]\n", c.ProfileName))
-	sb.WriteString(fmt.Sprintf("# Assumed Role ID: %s\n", c.Raw.AssumedRoleUser.AssumedRoleId))
-	sb.WriteString(fmt.Sprintf("# Assumed Role ARN: %s\n", c.Raw.AssumedRoleUser.Arn))
-	sb.WriteString(fmt.Sprintf("region=%s\n", c.DefaultRegion))
-	sb.WriteString(fmt.Sprintf("aws_access_key_id=%s\n", c.AccessKeyId))
-	sb.WriteString(fmt.Sprintf("aws_secret_access_key=%s\n", c.SecretAccessKey))
-	sb.WriteString(fmt.Sprintf("aws_session_token=%s\n", c.SessionToken))
-	fh, err := os.OpenFile(fp, ff, 0600)
-	if err != nil {
-		return fmt.Errorf("Erred opening %s (existing: %t) for writing %s profile: %s", fp, isFileExists, c.ProfileName, err)
+	vars = append(vars, EnvVar{"AWS_ACCESS_KEY_ID", c.AccessKeyId})
+	vars = append(vars, EnvVar{"AWS_SECRET_ACCESS_KEY", c.SecretAccessKey})
+	vars = append(vars, EnvVar{"AWS_SESSION_TOKEN", c.SessionToken})
+	if !c.Expiration.IsZero() {
+		vars = append(vars, EnvVar{"AWS_CREDENTIAL_EXPIRATION", c.Expiration.UTC().Format(time.RFC3339)})
 	}
-	defer fh.Close()
-	_, err = fh.WriteString(sb.String())
-	if err != nil {
-		return fmt.Errorf("Erred writing %s profile to %s (existing: %t): %s", c.ProfileName, fp, isFileExists, err)
+	return vars
+}
+
+// GetEnvVarsScript returns the environment variables returned by
+// GetEnvVars in a format, e.g. to be evaluated by a shell.
+func (c *AwsCredentials) GetEnvVarsScript(format string) ([]byte, error) {
+	if err := c.IsValid(); err != nil {
+		return nil, err
 	}
-	log.Infof("Added %s aws credentials profile to %s", c.ProfileName, fp)
-	return nil
+	comments := []string{}
+	if c.Raw != nil && c.Raw.AssumedRoleUser != nil {
+		comments = append(comments, "Assumed Role ID: "+c.Raw.AssumedRoleUser.AssumedRoleId)
+		comments = append(comments, "Assumed Role ARN: "+c.Raw.AssumedRoleUser.Arn)
+	}
+	return FormatEnvVars(c.GetEnvVars(), comments, format)
 }
 
 // WriteEnvVarsFile writes an environment variables file which
-// exports `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY`, and
-// `AWS_SESSION_TOKEN` environment variables.
+// exports the environment variables returned by GetEnvVars, in the
+// default format of the operating system.
 func (c *AwsCredentials) WriteEnvVarsFile(fp string) error {
-	if err := c.IsValid(); err != nil {
+	return c.WriteEnvVarsFileWithFormat(fp, "")
+}
+
+// WriteEnvVarsFileWithFormat writes an environment variables file in a
+// format, e.g. `fish` or `docker`. See GetEnvFormats for the supported
+// formats.
+func (c *AwsCredentials) WriteEnvVarsFileWithFormat(fp, format string) error {
+	data, err := c.GetEnvVarsScript(format)
+	if err != nil {
 		return err
 	}
-	var sb strings.Builder
-	exportWord := "export"
-	sep := "="
-	commentWord := "#"
-	if runtime.GOOS == "windows" {
-		exportWord = "SETX"
-		sep = " "
-		commentWord = "REM"
-	}
-	sb.WriteString(fmt.Sprintf("%s Assumed Role ID: %s\n", commentWord, c.Raw.AssumedRoleUser.AssumedRoleId))
-	sb.WriteString(fmt.Sprintf("%s Assumed Role ARN: %s\n", commentWord, c.Raw.AssumedRoleUser.Arn))
-	sb.WriteString(fmt.Sprintf("%s AWS_DEFAULT_REGION%s%s\n", exportWord, sep, c.DefaultRegion))
-	sb.WriteString(fmt.Sprintf("%s AWS_ACCESS_KEY_ID%s%s\n", exportWord, sep, c.AccessKeyId))
-	sb.WriteString(fmt.Sprintf("%s AWS_SECRET_ACCESS_KEY%s%s\n", exportWord, sep, c.SecretAccessKey))
-	sb.WriteString(fmt.Sprintf("%s AWS_SESSION_TOKEN%s%s\n", exportWord, sep, c.SessionToken))
-	if err := ioutil.WriteFile(fp, []byte(sb.String()), 0600); err != nil {
+	fp = ExpandFilePath(fp)
+	if err := writeFileAtomic(fp, data, 0600); err != nil {
 		return fmt.Errorf("Erred writing environment variables to %s: %s", fp, err)
 	}
 	log.Debugf("Wrote environment variables to %s", fp)
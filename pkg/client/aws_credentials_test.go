@@ -3,12 +3,14 @@ package client
 import (
 	"io/ioutil"
 	"path"
+	"strings"
 	"testing"
 )
 
 func TestWriteAwsCredentials(t *testing.T) {
 	testFailed := 0
 	assetDir := "../../assets/tests"
+	tmpDir := t.TempDir()
 	for i, test := range []struct {
 		input      string
 		shouldFail bool
@@ -40,7 +42,7 @@ func TestWriteAwsCredentials(t *testing.T) {
 			continue
 		}
 
-		envFilePath := path.Join(assetDir, test.input+".env")
+		envFilePath := path.Join(tmpDir, test.input+".env")
 		if err := awsCredentials.WriteEnvVarsFile(envFilePath); err != nil {
 			if !test.shouldFail {
 				t.Logf("FAIL: Test %d: input '%s', expected to pass, but failed: %s",
@@ -49,7 +51,13 @@ func TestWriteAwsCredentials(t *testing.T) {
 			}
 		}
 
-		iniFilePath := path.Join(assetDir, test.input+".ini")
+		// The existing credentials file is updated in a copy.
+		iniFilePath := path.Join(tmpDir, test.input+".ini")
+		if content, err := ioutil.ReadFile(path.Join(assetDir, test.input+".ini")); err == nil {
+			if err := ioutil.WriteFile(iniFilePath, content, 0600); err != nil {
+				t.Fatalf("failed copying '%s', error: %v", iniFilePath, err)
+			}
+		}
 		if err := awsCredentials.WriteCredentialsFile(iniFilePath); err != nil {
 			if !test.shouldFail {
 				t.Logf("FAIL: Test %d: input '%s', expected to pass, but failed: %s",
@@ -152,3 +160,49 @@ func TestIsValidAwsCredentials(t *testing.T) {
 		t.Fatalf("Failed %d tests", testFailed)
 	}
 }
+
+func TestGetEnvVarsScript(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		name    string
+		raw     *AwsStsResponse
+		comment string
+	}{
+		{name: "without STS response"},
+		// The cached credentials, or the STS response, may lack the
+		// assumed role user.
+		{name: "without assumed role user", raw: &AwsStsResponse{}},
+		{
+			name: "with assumed role user",
+			raw: &AwsStsResponse{AssumedRoleUser: &AssumedRoleUser{
+				AssumedRoleId: "AROAJ2UCCR6DPCEXAMPLE:jsmith@contoso.com",
+				Arn:           "arn:aws:sts::795318967487:assumed-role/Administrator/jsmith@contoso.com",
+			}},
+			comment: "Assumed Role ARN: arn:aws:sts::795318967487:assumed-role/Administrator/jsmith@contoso.com",
+		},
+	} {
+		creds := &AwsCredentials{
+			AccessKeyId:     "ASBGQSJR7ZAFSXODTUMO",
+			SecretAccessKey: "YTU1OTE0OTgtYjU1Ni00YTMzLWE1MDYtZmJjN2Jh",
+			SessionToken:    "MmY2MjdmMmItODA4NS00MTk3LTk3ZjYtYzhkNDE5NWYyNjEwCg==",
+			Raw:             test.raw,
+		}
+		out, err := creds.GetEnvVarsScript(EnvFormatBash)
+		if err != nil {
+			t.Logf("FAIL: Test %d: %s, unexpected error: %v", i, test.name, err)
+			testFailed++
+			continue
+		}
+		if !strings.Contains(string(out), "AWS_ACCESS_KEY_ID=ASBGQSJR7ZAFSXODTUMO") ||
+			strings.Contains(string(out), "Assumed Role") != (test.comment != "") ||
+			!strings.Contains(string(out), test.comment) {
+			t.Logf("FAIL: Test %d: %s, unexpected output:\n%s", i, test.name, out)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: %s, expected to pass, passed", i, test.name)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
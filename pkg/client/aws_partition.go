@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultAwsPartition is the name of the standard AWS partition.
+const DefaultAwsPartition = "aws"
+
+// AwsPartition is the structure holding the endpoints of an AWS partition,
+// e.g. AWS GovCloud (US) or AWS China.
+type AwsPartition struct {
+	Name string
+	// STSURL is the endpoint for AssumeRoleWithSAML API calls.
+	STSURL string
+	// SamlConsumerURL is the URL of AWS SAML assertion consumer service.
+	SamlConsumerURL string
+	// RelyingPartyURN is the identifier of AWS as SAML relying party.
+	RelyingPartyURN string
+	DefaultRegion   string
+}
+
+var awsPartitions = map[string]*AwsPartition{
+	"aws": {
+		Name:            "aws",
+		STSURL:          "https://sts.amazonaws.com/",
+		SamlConsumerURL: AwsSamlConsumerURL,
+		RelyingPartyURN: AwsRelyingPartyURN,
+		DefaultRegion:   "us-east-1",
+	},
+	"aws-us-gov": {
+		Name:            "aws-us-gov",
+		STSURL:          "https://sts.us-gov-west-1.amazonaws.com/",
+		SamlConsumerURL: "https://signin.amazonaws-us-gov.com/saml",
+		RelyingPartyURN: "urn:amazon:webservices:govcloud",
+		DefaultRegion:   "us-gov-west-1",
+	},
+	"aws-cn": {
+		Name:            "aws-cn",
+		STSURL:          "https://sts.cn-north-1.amazonaws.com.cn/",
+		SamlConsumerURL: "https://signin.amazonaws.cn/saml",
+		RelyingPartyURN: "urn:amazon:webservices:cn-north-1",
+		DefaultRegion:   "cn-north-1",
+	},
+	"aws-iso": {
+		Name:            "aws-iso",
+		STSURL:          "https://sts.us-iso-east-1.c2s.ic.gov/",
+		SamlConsumerURL: "https://signin.c2shome.ic.gov/saml",
+		RelyingPartyURN: "urn:amazon:webservices:iso",
+		DefaultRegion:   "us-iso-east-1",
+	},
+	"aws-iso-b": {
+		Name:            "aws-iso-b",
+		STSURL:          "https://sts.us-isob-east-1.sc2s.sgov.gov/",
+		SamlConsumerURL: "https://signin.sc2shome.sgov.gov/saml",
+		RelyingPartyURN: "urn:amazon:webservices:iso-b",
+		DefaultRegion:   "us-isob-east-1",
+	},
+}
+
+// GetAwsPartition returns AWS partition by its name, e.g. aws-us-gov.
+// The standard partition is returned when the name is empty.
+func GetAwsPartition(s string) (*AwsPartition, error) {
+	if s == "" {
+		s = DefaultAwsPartition
+	}
+	p, exists := awsPartitions[strings.ToLower(s)]
+	if !exists {
+		return nil, fmt.Errorf("unsupported AWS partition: %s", s)
+	}
+	return p, nil
+}
+
+// GetAwsPartitionNames returns the names of supported AWS partitions.
+func GetAwsPartitionNames() []string {
+	return []string{"aws", "aws-us-gov", "aws-cn", "aws-iso", "aws-iso-b"}
+}
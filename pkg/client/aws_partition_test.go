@@ -0,0 +1,88 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestParseAwsRolePartition(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		input     string
+		partition string
+		accountID string
+		name      string
+		stsURL    string
+		shouldErr bool
+	}{
+		{
+			input:     "arn:aws:iam::795318967487:saml-provider/ADFS,arn:aws:iam::795318967487:role/Administrator",
+			partition: "aws",
+			accountID: "795318967487",
+			name:      "Administrator",
+			stsURL:    "https://sts.amazonaws.com/",
+		},
+		{
+			input:     "arn:aws-us-gov:iam::123456789012:saml-provider/ADFS,arn:aws-us-gov:iam::123456789012:role/ReadOnly",
+			partition: "aws-us-gov",
+			accountID: "123456789012",
+			name:      "ReadOnly",
+			stsURL:    "https://sts.us-gov-west-1.amazonaws.com/",
+		},
+		{
+			input:     "arn:aws-cn:iam::123456789012:saml-provider/ADFS,arn:aws-cn:iam::123456789012:role/path/Operator",
+			partition: "aws-cn",
+			accountID: "123456789012",
+			name:      "Operator",
+			stsURL:    "https://sts.cn-north-1.amazonaws.com.cn/",
+		},
+		{
+			input:     "arn:aws-iso:iam::123456789012:saml-provider/ADFS,arn:aws-iso:iam::123456789012:role/Operator",
+			partition: "aws-iso",
+			accountID: "123456789012",
+			name:      "Operator",
+			stsURL:    "https://sts.us-iso-east-1.c2s.ic.gov/",
+		},
+		{
+			input:     "arn:aws:iam::123456789012:saml-provider/ADFS,arn:aws-cn:iam::123456789012:role/Operator",
+			shouldErr: true,
+		},
+		{
+			input:     "arn:aws-mars:iam::123456789012:saml-provider/ADFS,arn:aws-mars:iam::123456789012:role/Operator",
+			shouldErr: true,
+		},
+	} {
+		role, err := ParseAwsRole(test.input)
+		if err != nil {
+			if !test.shouldErr {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input '%s', expected to throw error, threw: %v", i, test.input, err)
+			continue
+		}
+		if test.shouldErr {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw error, but passed: %v", i, test.input, role)
+			testFailed++
+			continue
+		}
+		if role.Partition != test.partition || role.AccountID != test.accountID || role.Name != test.name {
+			t.Logf("FAIL: Test %d: input '%s', mismatch %s/%s/%s (expected) vs %s/%s/%s",
+				i, test.input, test.partition, test.accountID, test.name, role.Partition, role.AccountID, role.Name)
+			testFailed++
+			continue
+		}
+		cli := New()
+		stsURL, err := cli.GetAwsStsURL(role)
+		if err != nil || stsURL != test.stsURL {
+			t.Logf("FAIL: Test %d: input '%s', STS URL mismatch %s (expected) vs %s, error: %v",
+				i, test.input, test.stsURL, stsURL, err)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
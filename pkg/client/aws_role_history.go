@@ -0,0 +1,71 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultAwsRoleHistoryFile is the file with the recently used AWS
+	// roles, most recent first.
+	DefaultAwsRoleHistoryFile = "~/.aws/go-get-aws-keys/history"
+	// maxAwsRoleHistory is the number of the roles kept in the history.
+	maxAwsRoleHistory = 20
+)
+
+// ReadAwsRoleHistory returns the ARNs of the recently used AWS roles, most
+// recent first. A missing file is an empty history.
+func ReadAwsRoleHistory(fp string) []string {
+	b, err := ioutil.ReadFile(ExpandFilePath(fp))
+	if err != nil {
+		return []string{}
+	}
+	history := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+// UpdateAwsRoleHistory moves the ARNs of the used AWS roles to the top of
+// the history file.
+func UpdateAwsRoleHistory(fp string, arns []string) error {
+	history := append([]string{}, arns...)
+	for _, arn := range ReadAwsRoleHistory(fp) {
+		if !containsString(history, arn) {
+			history = append(history, arn)
+		}
+	}
+	if len(history) > maxAwsRoleHistory {
+		history = history[:maxAwsRoleHistory]
+	}
+	fp = ExpandFilePath(fp)
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(fp, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
+
+// SortAwsRoleListByHistory puts the recently used AWS roles first, most
+// recent first. The order of the other roles does not change.
+func SortAwsRoleListByHistory(items []*AwsRoleListItem, history []string) {
+	rank := map[string]int{}
+	for i, arn := range history {
+		if _, exists := rank[arn]; !exists {
+			rank[arn] = i
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		ri, iok := rank[items[i].RoleARN]
+		rj, jok := rank[items[j].RoleARN]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// AwsRoleListItem is the structure holding an AWS role granted by IdP.
+type AwsRoleListItem struct {
+	AccountID           string `json:"account_id" yaml:"account_id"`
+	AccountAlias        string `json:"account_alias,omitempty" yaml:"account_alias,omitempty"`
+	Name                string `json:"name" yaml:"name"`
+	RoleARN             string `json:"role_arn" yaml:"role_arn"`
+	IdentityProviderARN string `json:"principal_arn" yaml:"principal_arn"`
+	Partition           string `json:"partition" yaml:"partition"`
+}
+
+// GetAccountAlias returns the alias of AWS account from the configuration,
+// or an empty string.
+func (c *Client) GetAccountAlias(accountID string) string {
+	return c.Config.Aws.AccountAliases[accountID]
+}
+
+// ListAwsRoles authenticates to IdP and returns the AWS roles in SAML
+// assertions. Unlike GetAwsCredentials, it does not call AWS STS, and the
+// requested roles are not required.
+func (c *Client) ListAwsRoles() ([]*AwsRoleListItem, error) {
+	if err := c.GetAdfsMetadata(); err != nil {
+		return nil, err
+	}
+	if err := c.GetSamlAssertions(); err != nil {
+		return nil, err
+	}
+	return c.GetAwsRoleList(), nil
+}
+
+// GetAwsRoleList returns the AWS roles in the received SAML assertions,
+// sorted by account and role name.
+func (c *Client) GetAwsRoleList() []*AwsRoleListItem {
+	items := []*AwsRoleListItem{}
+	if c.Runtime.Saml.Attributes == nil {
+		return items
+	}
+	for _, role := range c.Runtime.Saml.Attributes.Aws.Roles {
+		items = append(items, &AwsRoleListItem{
+			AccountID:           role.AccountID,
+			AccountAlias:        c.GetAccountAlias(role.AccountID),
+			Name:                role.Name,
+			RoleARN:             role.RoleARN,
+			IdentityProviderARN: role.IdentityProviderARN,
+			Partition:           role.Partition,
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].AccountID != items[j].AccountID {
+			return items[i].AccountID < items[j].AccountID
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// FormatAwsRoleList renders the roles as a table, or in a format, i.e.
+// `json` or `yaml`.
+func FormatAwsRoleList(items []*AwsRoleListItem, format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case "":
+		var sb strings.Builder
+		w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ACCOUNT ID\tALIAS\tROLE\tROLE ARN\tPROVIDER ARN\tPARTITION")
+		for _, item := range items {
+			alias := item.AccountAlias
+			if alias == "" {
+				alias = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", item.AccountID, alias, item.Name,
+				item.RoleARN, item.IdentityProviderARN, item.Partition)
+		}
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+		return []byte(sb.String()), nil
+	case ResultFormatJSON:
+		b, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case ResultFormatYAML:
+		return yaml.Marshal(items)
+	}
+	return nil, fmt.Errorf("unsupported output format: %s, supported formats: %s",
+		format, strings.Join(GetResultFormats(), ", "))
+}
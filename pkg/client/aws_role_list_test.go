@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGetAwsRoleList(t *testing.T) {
+	fp := path.Join("../../assets/tests", "saml2.response.roles.xml")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	var r SamlResponse
+	if err := xml.Unmarshal(content, &r); err != nil {
+		t.Fatalf("failed to unmarshal '%s': %v", fp, err)
+	}
+	attrs, err := r.GetAttributes()
+	if err != nil {
+		t.Fatalf("failed to get attributes of '%s': %v", fp, err)
+	}
+	c := New()
+	c.Runtime.Saml.Attributes = attrs
+	c.Config.Aws.AccountAliases = map[string]string{"795318967487": "production"}
+	items := c.GetAwsRoleList()
+
+	testFailed := 0
+	for i, test := range []struct {
+		accountID string
+		alias     string
+		name      string
+	}{
+		{accountID: "399230634940", name: "ReadOnly"},
+		{accountID: "795318967487", alias: "production", name: "Administrator"},
+		{accountID: "795318967487", alias: "production", name: "ReadOnly"},
+	} {
+		if i >= len(items) {
+			t.Logf("FAIL: Test %d: expected %s role on %s account, but got %d roles", i, test.name, test.accountID, len(items))
+			testFailed++
+			continue
+		}
+		item := items[i]
+		if item.AccountID != test.accountID || item.AccountAlias != test.alias || item.Name != test.name ||
+			item.Partition != "aws" || !strings.HasSuffix(item.RoleARN, ":role/"+test.name) {
+			t.Logf("FAIL: Test %d: unexpected role: %+v", i, item)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: role '%s', expected to pass, passed", i, item.RoleARN)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+
+	table, err := FormatAwsRoleList(items, "")
+	if err != nil {
+		t.Fatalf("failed formatting table: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(table)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "ACCOUNT ID") || !strings.Contains(lines[2], "production") {
+		t.Fatalf("FAIL: unexpected table:\n%s", table)
+	}
+	b, err := FormatAwsRoleList(items, ResultFormatJSON)
+	if err != nil {
+		t.Fatalf("failed formatting JSON: %v", err)
+	}
+	parsed := []*AwsRoleListItem{}
+	if err := json.Unmarshal(b, &parsed); err != nil || len(parsed) != 3 {
+		t.Fatalf("FAIL: unexpected JSON: %s (%v)", b, err)
+	}
+}
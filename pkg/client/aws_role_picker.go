@@ -0,0 +1,274 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrNotTerminal is returned when a user can not pick AWS roles, because
+// the standard input is not a terminal.
+var ErrNotTerminal = errors.New("stdin is not a terminal")
+
+// ErrPickerCancelled is returned when a user cancels picking AWS roles.
+var ErrPickerCancelled = errors.New("cancelled picking AWS roles")
+
+// maxPickerRows is the number of the roles displayed at once.
+const maxPickerRows = 15
+
+type pickerKey int
+
+const (
+	pickerKeyRune pickerKey = iota
+	pickerKeyUp
+	pickerKeyDown
+	pickerKeyToggle
+	pickerKeyEnter
+	pickerKeyBackspace
+	pickerKeyCancel
+)
+
+type pickerEvent struct {
+	Key  pickerKey
+	Rune rune
+}
+
+// awsRolePicker is the state of the picker: the filter typed by a user,
+// the position of the cursor among the matching roles, and the selected
+// roles.
+type awsRolePicker struct {
+	items    []*AwsRoleListItem
+	multi    bool
+	filter   []rune
+	cursor   int
+	selected map[*AwsRoleListItem]bool
+}
+
+func newAwsRolePicker(items []*AwsRoleListItem, multi bool) *awsRolePicker {
+	return &awsRolePicker{
+		items:    items,
+		multi:    multi,
+		selected: make(map[*AwsRoleListItem]bool),
+	}
+}
+
+// getLabel returns the text the filter is matched against.
+func (item *AwsRoleListItem) getLabel() string {
+	if item.AccountAlias != "" {
+		return item.AccountID + " (" + item.AccountAlias + ") " + item.Name
+	}
+	return item.AccountID + " " + item.Name
+}
+
+// visible returns the roles matching the filter. Every word of the filter
+// must appear in the account ID, alias, or role name, ignoring case.
+func (p *awsRolePicker) visible() []*AwsRoleListItem {
+	words := strings.Fields(strings.ToLower(string(p.filter)))
+	items := []*AwsRoleListItem{}
+	for _, item := range p.items {
+		label := strings.ToLower(item.getLabel())
+		isMatch := true
+		for _, w := range words {
+			if !strings.Contains(label, w) {
+				isMatch = false
+				break
+			}
+		}
+		if isMatch {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// handle applies a key press. It returns the picked roles when a user
+// confirms the selection.
+func (p *awsRolePicker) handle(ev pickerEvent) ([]*AwsRoleListItem, error) {
+	visible := p.visible()
+	switch ev.Key {
+	case pickerKeyRune:
+		p.filter = append(p.filter, ev.Rune)
+		p.cursor = 0
+	case pickerKeyBackspace:
+		if len(p.filter) > 0 {
+			p.filter = p.filter[:len(p.filter)-1]
+			p.cursor = 0
+		}
+	case pickerKeyUp:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case pickerKeyDown:
+		if p.cursor < len(visible)-1 {
+			p.cursor++
+		}
+	case pickerKeyToggle:
+		if p.multi && p.cursor < len(visible) {
+			item := visible[p.cursor]
+			if p.selected[item] {
+				delete(p.selected, item)
+			} else {
+				p.selected[item] = true
+			}
+			if p.cursor < len(visible)-1 {
+				p.cursor++
+			}
+		}
+	case pickerKeyEnter:
+		picked := []*AwsRoleListItem{}
+		for _, item := range p.items {
+			if p.selected[item] {
+				picked = append(picked, item)
+			}
+		}
+		if len(picked) > 0 {
+			return picked, nil
+		}
+		if p.cursor < len(visible) {
+			return []*AwsRoleListItem{visible[p.cursor]}, nil
+		}
+	case pickerKeyCancel:
+		return nil, ErrPickerCancelled
+	}
+	return nil, nil
+}
+
+// render returns the lines of the picker.
+func (p *awsRolePicker) render() []string {
+	help := "type to filter, Up/Down to move, Enter to confirm, Esc to cancel"
+	if p.multi {
+		help = "type to filter, Up/Down to move, Tab to select, Enter to confirm, Esc to cancel"
+	}
+	lines := []string{
+		"Select AWS role (" + help + ")",
+		"Filter: " + string(p.filter),
+	}
+	visible := p.visible()
+	if len(visible) == 0 {
+		return append(lines, "  no matching roles")
+	}
+	start := 0
+	if p.cursor >= maxPickerRows {
+		start = p.cursor - maxPickerRows + 1
+	}
+	for i := start; i < len(visible) && i < start+maxPickerRows; i++ {
+		item := visible[i]
+		prefix := "  "
+		if i == p.cursor {
+			prefix = "> "
+		}
+		if p.multi {
+			if p.selected[item] {
+				prefix += "[x] "
+			} else {
+				prefix += "[ ] "
+			}
+		}
+		lines = append(lines, prefix+item.getLabel())
+	}
+	if n := len(visible) - start - maxPickerRows; n > 0 {
+		lines = append(lines, fmt.Sprintf("  ... %d more", n))
+	}
+	return lines
+}
+
+// parsePickerInput converts the bytes read from a terminal in raw mode to
+// key presses.
+func parsePickerInput(b []byte) []pickerEvent {
+	events := []pickerEvent{}
+	for len(b) > 0 {
+		switch {
+		case b[0] == 0x1b && len(b) >= 3 && (b[1] == '[' || b[1] == 'O'):
+			switch b[2] {
+			case 'A':
+				events = append(events, pickerEvent{Key: pickerKeyUp})
+			case 'B':
+				events = append(events, pickerEvent{Key: pickerKeyDown})
+			}
+			b = b[3:]
+			continue
+		case b[0] == 0x1b, b[0] == 0x03, b[0] == 0x04:
+			// Esc, Ctrl-C, Ctrl-D
+			events = append(events, pickerEvent{Key: pickerKeyCancel})
+		case b[0] == '\r', b[0] == '\n':
+			events = append(events, pickerEvent{Key: pickerKeyEnter})
+		case b[0] == '\t':
+			events = append(events, pickerEvent{Key: pickerKeyToggle})
+		case b[0] == 0x7f, b[0] == 0x08:
+			events = append(events, pickerEvent{Key: pickerKeyBackspace})
+		case b[0] == 0x10:
+			// Ctrl-P
+			events = append(events, pickerEvent{Key: pickerKeyUp})
+		case b[0] == 0x0e:
+			// Ctrl-N
+			events = append(events, pickerEvent{Key: pickerKeyDown})
+		default:
+			r, size := utf8.DecodeRune(b)
+			if unicode.IsPrint(r) {
+				events = append(events, pickerEvent{Key: pickerKeyRune, Rune: r})
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return events
+}
+
+// IsTerminal checks whether a file, e.g. stdin, is a terminal.
+func IsTerminal(f *os.File) bool {
+	return terminal.IsTerminal(int(f.Fd()))
+}
+
+// PickAwsRoles lets a user pick AWS roles in the terminal. With multi, a
+// user could select more than one role. The picker is drawn on out, e.g.
+// stderr.
+func PickAwsRoles(items []*AwsRoleListItem, multi bool, in *os.File, out io.Writer) ([]*AwsRoleListItem, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("SAML assertions contain no AWS roles to pick from")
+	}
+	if !IsTerminal(in) {
+		return nil, ErrNotTerminal
+	}
+	state, err := terminal.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("Erred switching terminal to raw mode: %s", err)
+	}
+	defer terminal.Restore(int(in.Fd()), state)
+
+	p := newAwsRolePicker(items, multi)
+	drawn := 0
+	draw := func() {
+		var sb strings.Builder
+		if drawn > 1 {
+			sb.WriteString(fmt.Sprintf("\x1b[%dA", drawn-1))
+		}
+		sb.WriteString("\r\x1b[J")
+		lines := p.render()
+		sb.WriteString(strings.Join(lines, "\r\n"))
+		drawn = len(lines)
+		io.WriteString(out, sb.String())
+	}
+	draw()
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			io.WriteString(out, "\r\n")
+			return nil, err
+		}
+		for _, ev := range parsePickerInput(buf[:n]) {
+			picked, err := p.handle(ev)
+			if err != nil || picked != nil {
+				io.WriteString(out, "\r\n")
+				return picked, err
+			}
+		}
+		draw()
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func getTestAwsRoleList() []*AwsRoleListItem {
+	return []*AwsRoleListItem{
+		{AccountID: "000000000001", AccountAlias: "production", Name: "Administrator", RoleARN: "arn:aws:iam::000000000001:role/Administrator"},
+		{AccountID: "000000000001", AccountAlias: "production", Name: "ReadOnly", RoleARN: "arn:aws:iam::000000000001:role/ReadOnly"},
+		{AccountID: "000000000002", AccountAlias: "staging", Name: "Administrator", RoleARN: "arn:aws:iam::000000000002:role/Administrator"},
+		{AccountID: "000000000003", Name: "Developer", RoleARN: "arn:aws:iam::000000000003:role/Developer"},
+	}
+}
+
+func TestAwsRolePicker(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		input      string
+		multi      bool
+		exp        []string
+		shouldFail bool
+	}{
+		{input: "\r", exp: []string{"arn:aws:iam::000000000001:role/Administrator"}},
+		// Down arrow moves to the next role.
+		{input: "\x1b[B\x1b[B\r", exp: []string{"arn:aws:iam::000000000002:role/Administrator"}},
+		// The filter matches the alias and the role name.
+		{input: "prod read\r", exp: []string{"arn:aws:iam::000000000001:role/ReadOnly"}},
+		{input: "STAGING\r", exp: []string{"arn:aws:iam::000000000002:role/Administrator"}},
+		// Backspace removes the last character of the filter.
+		{input: "devx\x7f\r", exp: []string{"arn:aws:iam::000000000003:role/Developer"}},
+		// Tab selects the role and moves to the next one.
+		{input: "admin\t\t\r", multi: true, exp: []string{
+			"arn:aws:iam::000000000001:role/Administrator",
+			"arn:aws:iam::000000000002:role/Administrator",
+		}},
+		// Tab does not select in single mode.
+		{input: "\t\t\r", exp: []string{"arn:aws:iam::000000000001:role/Administrator"}},
+		{input: "nomatch\r\x1b", shouldFail: true},
+		{input: "\x03", shouldFail: true},
+	} {
+		p := newAwsRolePicker(getTestAwsRoleList(), test.multi)
+		var picked []*AwsRoleListItem
+		var err error
+		for _, ev := range parsePickerInput([]byte(test.input)) {
+			picked, err = p.handle(ev)
+			if err != nil || picked != nil {
+				break
+			}
+			if len(p.render()) < 3 {
+				t.Fatalf("FAIL: Test %d: input %q, picker rendered no roles", i, test.input)
+			}
+		}
+		if err != nil {
+			if !test.shouldFail {
+				t.Logf("FAIL: Test %d: input %q, expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input %q, expected to fail, failed: %v", i, test.input, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: input %q, expected to fail, but passed", i, test.input)
+			testFailed++
+			continue
+		}
+		arns := []string{}
+		for _, item := range picked {
+			arns = append(arns, item.RoleARN)
+		}
+		if strings.Join(arns, ",") != strings.Join(test.exp, ",") {
+			t.Logf("FAIL: Test %d: input %q, expected %v, but got %v", i, test.input, test.exp, arns)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input %q, expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestAwsRoleHistory(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ggk-history")
+	if err != nil {
+		t.Fatalf("failed creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	fp := path.Join(tmpDir, "history")
+	if history := ReadAwsRoleHistory(fp); len(history) != 0 {
+		t.Fatalf("FAIL: expected empty history, but got %v", history)
+	}
+	if err := UpdateAwsRoleHistory(fp, []string{"arn:aws:iam::000000000003:role/Developer"}); err != nil {
+		t.Fatalf("failed updating history: %v", err)
+	}
+	if err := UpdateAwsRoleHistory(fp, []string{"arn:aws:iam::000000000002:role/Administrator"}); err != nil {
+		t.Fatalf("failed updating history: %v", err)
+	}
+	items := getTestAwsRoleList()
+	SortAwsRoleListByHistory(items, ReadAwsRoleHistory(fp))
+	exp := []string{
+		"arn:aws:iam::000000000002:role/Administrator",
+		"arn:aws:iam::000000000003:role/Developer",
+		"arn:aws:iam::000000000001:role/Administrator",
+		"arn:aws:iam::000000000001:role/ReadOnly",
+	}
+	for i, item := range items {
+		if item.RoleARN != exp[i] {
+			t.Fatalf("FAIL: expected %s at position %d, but got %s", exp[i], i, item.RoleARN)
+		}
+	}
+	t.Logf("PASS: the recently used roles come first")
+}
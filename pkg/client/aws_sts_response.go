@@ -4,15 +4,53 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // AwsStsResponseCredentials contains the Credentials part of AwsStsResponse.
 type AwsStsResponseCredentials struct {
 	SecretAccessKey string
 	SessionToken    string
-	//Expiration      string
-	//Expiration  time.Time
-	AccessKeyId string
+	Expiration      AwsStsTimestamp
+	AccessKeyId     string
+}
+
+// AwsStsTimestamp is the timestamp in AWS STS response. AWS STS JSON
+// responses have the number of seconds since epoch, e.g. 1.5633660E9,
+// while the documented form is ISO8601, e.g. 2019-07-17T12:18:00Z.
+type AwsStsTimestamp struct {
+	time.Time
+}
+
+// UnmarshalJSON parses either epoch seconds or ISO8601 timestamp.
+func (t *AwsStsTimestamp) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	if s == "null" || s == `""` {
+		t.Time = time.Time{}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var v string
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return fmt.Errorf("invalid AWS STS timestamp: %s", v)
+		}
+		t.Time = ts.UTC()
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid AWS STS timestamp: %s", s)
+	}
+	sec, frac := math.Modf(f)
+	t.Time = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	return nil
 }
 
 // AwsStsResponseAssumedRoleUser contains the AssumedRoleUser part of AwsStsResponse.
@@ -66,6 +104,23 @@ type AwsStsErrorResponseBody struct {
 	AwsStsResponseMetadata
 }
 
+// AwsStsError is the error returned by AWS STS API endpoint.
+type AwsStsError struct {
+	RequestID string
+	Code      string
+	Message   string
+}
+
+func (e *AwsStsError) Error() string {
+	return fmt.Sprintf("AWS STS %s: %s: %s ", e.RequestID, e.Code, e.Message)
+}
+
+// IsSessionDurationError checks whether AWS STS rejected DurationSeconds,
+// e.g. because it exceeds MaxSessionDuration of the role.
+func (e *AwsStsError) IsSessionDurationError() bool {
+	return e.Code == "ValidationError" && strings.Contains(e.Message, "DurationSeconds")
+}
+
 // NewAwsStsResponseFromString returns AwsStsResponse instance from an input string.
 func NewAwsStsResponseFromString(s string) (*AwsStsResponse, error) {
 	return NewAwsStsResponseFromBytes([]byte(s))
@@ -78,7 +133,11 @@ func NewAwsStsResponseFromBytes(s []byte) (*AwsStsResponse, error) {
 		if err := json.Unmarshal(s, errResp); err != nil {
 			return nil, fmt.Errorf("parsing error: %s, AWS STS response: %s", err, string(s[:]))
 		}
-		return nil, fmt.Errorf("AWS STS %s: %s: %s ", errResp.RequestId, errResp.Error.Code, errResp.Error.Message)
+		return nil, &AwsStsError{
+			RequestID: errResp.RequestId,
+			Code:      errResp.Error.Code,
+			Message:   errResp.Error.Message,
+		}
 	}
 	if bytes.Contains(s, []byte("ResponseMetadata")) {
 		extResp := &AwsStsResponseBody{}
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestParseAwsStsResponse(t *testing.T) {
@@ -63,3 +64,44 @@ func TestParseAwsStsResponse(t *testing.T) {
 		t.Fatalf("Failed %d tests", testFailed)
 	}
 }
+
+func TestParseAwsStsExpiration(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		input      string
+		expiration string
+	}{
+		{input: "aws.sts.response.1.json", expiration: "2019-07-17T12:18:00Z"},
+		{input: "aws.sts.response.3.json", expiration: "2019-07-17T12:18:00Z"},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		resp, err := NewAwsStsResponseFromBytes(content)
+		if err != nil {
+			t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		creds, err := NewAwsCredentialsFromStsResponse(resp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+			testFailed++
+			continue
+		}
+		if expiration := creds.Expiration.Format(time.RFC3339); expiration != test.expiration {
+			t.Logf("FAIL: Test %d: input '%s', expiration mismatch %s (expected) vs %s", i, test.input, test.expiration, expiration)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
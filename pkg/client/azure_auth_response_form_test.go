@@ -26,6 +26,18 @@ func TestParseAzureAuthResponseForm(t *testing.T) {
 			shouldFail: false,
 			shouldErr:  false,
 		},
+		{
+			input: "adfs.saml.response.form.html",
+			exp: &AzureAuthResponseForm{
+				Host: "signin.aws.amazon.com",
+				Fields: map[string]string{
+					"SAMLResponse": "TheTextOfSamlResponse",
+					"RelayState":   "urn:amazon:webservices",
+				},
+			},
+			shouldFail: false,
+			shouldErr:  false,
+		},
 	} {
 		fp := path.Join(assetDir, test.input)
 		content, err := ioutil.ReadFile(fp)
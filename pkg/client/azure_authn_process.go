@@ -1,8 +1,6 @@
 package client
 
 import (
-	"encoding/base64"
-	"encoding/xml"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
@@ -13,13 +11,11 @@ import (
 )
 
 // DoAzureAuthnRequestWithAdfs uses auto-accelleration feature to authenticate to IDP.
+// The users of cloud-only domains authenticate to Azure AD directly.
 func (c *Client) DoAzureAuthnRequestWithAdfs(r *AzureAuthnRequest) error {
 	if c.Config.Username == "" {
 		return fmt.Errorf("No username found for authentication")
 	}
-	if c.Config.Password == "" {
-		return fmt.Errorf("No password found for authentication")
-	}
 	// Step 1: Request goes to Azure and the expectation is that it
 	// redirects the request to IdP login page.
 
@@ -38,45 +34,81 @@ func (c *Client) DoAzureAuthnRequestWithAdfs(r *AzureAuthnRequest) error {
 	} else {
 		responseBody = string(body[:])
 	}
+	// With valid Azure AD session, e.g. ESTSAUTHPERSISTENT cookie, Azure
+	// responds with SAMLResponse right away.
+	if strings.Contains(responseBody, "\"SAMLResponse\"") {
+		azureAuthResponseForm, err := NewAzureAuthResponseFormFromString(responseBody)
+		if err != nil {
+			return fmt.Errorf("Error reading response form data from %s: %s", r.URL, err)
+		}
+		log.Debugf("Azure AD did not ask for credentials, reusing Azure AD session of %s", c.Config.Username)
+		return c.DecodeSamlResponse(azureAuthResponseForm.Fields["SAMLResponse"], "Azure Authentication Response Form")
+	}
+	// Cloud-only (managed) domains are not redirected to ADFS. Azure AD
+	// responds with its own login page.
+	if isAzureLoginPage(responseBody) {
+		return c.DoAzureLogin(resp.Request.URL, responseBody)
+	}
+	var adfsAuthResponseForm *AdfsAuthResponseForm
 	authForm, err := NewAdfsAuthFormFromString(responseBody)
 	if err != nil {
-		return fmt.Errorf("Error parsing response: %s", err)
-	}
-	// Step 2: Once we get an authentication form, we post our credentials
-	// to that form.
-	log.Debugf("ADFS Authentication Form: %v", authForm)
-	adfsFormEntries := url.Values{}
-	adfsFormEntries.Add("UserName", c.Config.Username)
-	adfsFormEntries.Add("Password", c.Config.Password)
-	adfsFormEntries.Add("Kmsi", "true")
-	adfsFormEntries.Add("AuthMethod", "FormsAuthentication")
-	adfsFormData := strings.NewReader(adfsFormEntries.Encode())
-	log.Debugf("ADFS Authentication URL: %s", authForm.URL)
-	log.Debugf("ADFS form data: %v", adfsFormEntries)
-	log.Debugf("ADFS form data (encoded): %v", adfsFormData)
-	adfsReq, err := http.NewRequest("POST", authForm.URL, adfsFormData)
-	if err != nil {
-		return fmt.Errorf("Error creating http post request: %s", err)
-	}
-	adfsReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	adfsReq.Header.Add("Content-Length", strconv.Itoa(len(adfsFormEntries.Encode())))
-	adfsResp, err := c.browser.Do(adfsReq)
-	if err != nil {
-		return fmt.Errorf("Error authenticating @ %s: %s", authForm.URL, err)
-	}
-	defer adfsResp.Body.Close()
-	adfsRespBodyBytes, err := ioutil.ReadAll(adfsResp.Body)
-	if err != nil {
-		return fmt.Errorf("Error reading response data from %s: %s", authForm.URL, err)
-	}
-	adfsRespBody := string(adfsRespBodyBytes[:])
-	log.Debugf("ADFS responded with %s: %s", adfsResp.Status, adfsRespBody)
-	if adfsResp.StatusCode != 200 {
-		return fmt.Errorf("ADFS form-based authentication failed")
-	}
-	adfsAuthResponseForm, err := NewAdfsAuthResponseFormFromString(adfsRespBody)
-	if err != nil {
-		return fmt.Errorf("Error reading form data from %s: %s", authForm.URL, err)
+		// With valid ADFS session, e.g. MSISAuth cookie, ADFS responds
+		// with the form redirecting back to Azure.
+		var formErr error
+		adfsAuthResponseForm, formErr = NewAdfsAuthResponseFormFromString(responseBody)
+		if formErr != nil {
+			return fmt.Errorf("Error parsing response: %s", err)
+		}
+		log.Debugf("ADFS did not ask for credentials, reusing ADFS session of %s", c.Config.Username)
+	} else {
+		// Step 2: Once we get an authentication form, we post our credentials
+		// to that form.
+		log.Debugf("ADFS Authentication Form: %v", authForm)
+		if err := c.requirePassword(); err != nil {
+			return err
+		}
+		adfsFormEntries := url.Values{}
+		adfsFormEntries.Add("UserName", c.Config.Username)
+		adfsFormEntries.Add("Password", c.Config.Password)
+		adfsFormEntries.Add("Kmsi", "true")
+		adfsFormEntries.Add("AuthMethod", "FormsAuthentication")
+		adfsFormData := strings.NewReader(adfsFormEntries.Encode())
+		log.Debugf("ADFS Authentication URL: %s", authForm.URL)
+		log.Debugf("ADFS form data: %v", adfsFormEntries)
+		log.Debugf("ADFS form data (encoded): %v", adfsFormData)
+		adfsReq, err := http.NewRequest("POST", authForm.URL, adfsFormData)
+		if err != nil {
+			return fmt.Errorf("Error creating http post request: %s", err)
+		}
+		adfsReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		adfsReq.Header.Add("Content-Length", strconv.Itoa(len(adfsFormEntries.Encode())))
+		adfsResp, err := c.browser.Do(adfsReq)
+		if err != nil {
+			return fmt.Errorf("Error authenticating @ %s: %s", authForm.URL, err)
+		}
+		defer adfsResp.Body.Close()
+		adfsRespBodyBytes, err := ioutil.ReadAll(adfsResp.Body)
+		if err != nil {
+			return fmt.Errorf("Error reading response data from %s: %s", authForm.URL, err)
+		}
+		adfsRespBody := string(adfsRespBodyBytes[:])
+		log.Debugf("ADFS responded with %s: %s", adfsResp.Status, adfsRespBody)
+		if adfsResp.StatusCode != 200 {
+			return fmt.Errorf("ADFS form-based authentication failed")
+		}
+		// With MFA adapter, ADFS responds with the additional
+		// authentication page first.
+		if _, err := NewAdfsMfaFormFromString(adfsRespBody); err == nil {
+			_, adfsRespBodyBytes, err = c.DoAdfsMfa(adfsResp.Request.URL, adfsRespBodyBytes)
+			if err != nil {
+				return err
+			}
+			adfsRespBody = string(adfsRespBodyBytes[:])
+		}
+		adfsAuthResponseForm, err = NewAdfsAuthResponseFormFromString(adfsRespBody)
+		if err != nil {
+			return fmt.Errorf("Error reading form data from %s: %s", authForm.URL, err)
+		}
 	}
 	log.Debugf("ADFS Authentication Response Form: %v", adfsAuthResponseForm)
 	// Step 3: Upon successful authentication, ADFS outputs the form
@@ -111,6 +143,11 @@ func (c *Client) DoAzureAuthnRequestWithAdfs(r *AzureAuthnRequest) error {
 	if azureTokenRequestResponse.StatusCode != 200 {
 		return fmt.Errorf("Azure authentication with ADFS form-based authentication response failed")
 	}
+	// Azure AD might ask for the second factor, e.g. by conditional
+	// access policy, before it responds with SAMLResponse.
+	if isAzureLoginPage(azureTokenRequestResponseBody) {
+		return c.DoAzureLogin(azureTokenRequestResponse.Request.URL, azureTokenRequestResponseBody)
+	}
 	azureAuthResponseForm, err := NewAzureAuthResponseFormFromString(azureTokenRequestResponseBody)
 	if err != nil {
 		return fmt.Errorf("Error reading response form data from %s: %s", adfsAuthResponseForm.URL, err)
@@ -119,23 +156,8 @@ func (c *Client) DoAzureAuthnRequestWithAdfs(r *AzureAuthnRequest) error {
 	// Step 4: Upon successful authentication with Azure using the ADFS response, we parse the form
 	// and extract SAMLResponse value for the submission to AWS STS Endpoint.
 
-	c.Runtime.Saml.Assertions = &SamlResponseAssertions{}
-
-	c.Runtime.Saml.Assertions.Raw, err = base64.StdEncoding.DecodeString(azureAuthResponseForm.Fields["SAMLResponse"])
-	if err != nil {
-		return fmt.Errorf("Failed to decode SAMLResponse in Azure Authentication Response Form: %s", err)
-	}
-	c.Runtime.Saml.Assertions.Plain = string(c.Runtime.Saml.Assertions.Raw[:])
-
-	if err := xml.Unmarshal(c.Runtime.Saml.Assertions.Raw, &c.Runtime.Saml.Response); err != nil {
-		return fmt.Errorf("Failed to unmarshal SAMLResponse in Azure Authentication Response Form: %s", err)
-	}
-	if c.Runtime.Saml.Response.Assertion.AttributeStatement == nil {
-		return fmt.Errorf("SAMLResponse in Azure Authentication Response Form does not contain attribute statements: %v", c.Runtime.Saml.Response.Assertion)
-	}
-	c.Runtime.Saml.Attributes, err = c.Runtime.Saml.Response.GetAttributes()
-	if err != nil {
-		return fmt.Errorf("Failed to get attributes from SAMLResponse in Azure Authentication Response Form: %s", err)
+	if err := c.DecodeSamlResponse(azureAuthResponseForm.Fields["SAMLResponse"], "Azure Authentication Response Form"); err != nil {
+		return err
 	}
 
 	return nil
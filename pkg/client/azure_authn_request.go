@@ -30,14 +30,15 @@ func (c *Client) GetAzureAuthnRequest() (*AzureAuthnRequest, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error generating UUID: %s", err)
 	}
+	partition := c.GetDefaultAwsPartition()
 	// Create a text template for the request
 	samlAuthRequestTemplate := `<samlp:AuthnRequest` +
 		` xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"` +
 		` xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"` +
 		` ID="AWSSAML{{ .ID }}"` +
 		` Version="2.0"` +
-		` AssertionConsumerServiceURL="https://signin.aws.amazon.com/saml"` +
-		` Destination="https://login.microsoftonline.com/` + c.Config.Azure.TenantID + `/saml2"` +
+		` AssertionConsumerServiceURL="` + partition.SamlConsumerURL + `"` +
+		` Destination="` + c.Runtime.AuthenticationURL + `"` +
 		` IssueInstant="{{ .Timestamp }}"` +
 		` ProtocolBinding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST">` +
 		`<saml:Issuer>{{ .Issuer }}</saml:Issuer>`
@@ -138,6 +139,6 @@ func (c *Client) GetAzureAuthnRequest() (*AzureAuthnRequest, error) {
 	r.ID = requestUUID.String()
 	r.TenantID = c.Config.Azure.TenantID
 	r.ApplicationID = c.Config.Azure.ApplicationID
-	r.ConsumerURL = "https://signin.aws.amazon.com/saml"
+	r.ConsumerURL = partition.SamlConsumerURL
 	return r, nil
 }
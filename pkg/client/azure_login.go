@@ -0,0 +1,186 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxAzureLoginPages is the maximum number of Azure AD login pages, e.g.
+// the sign-in page and "Stay signed in?" page, the client goes through
+// before it receives SAMLResponse.
+const maxAzureLoginPages = 5
+
+// The identifiers of Azure AD login pages, i.e. pgid in $Config.
+const (
+	AzureLoginPageSignIn    = "ConvergedSignIn"
+	AzureLoginPageKmsi      = "KmsiInterrupt"
+	AzureLoginPageMfa       = "ConvergedTFA"
+	AzureLoginPageError     = "ConvergedError"
+	AzureLoginPageProofUp   = "ConvergedProofUpRedirect"
+	AzureLoginPageChangePwd = "ConvergedChangePassword"
+)
+
+// AzureLoginConfig is the state of Azure AD login page, embedded in the
+// page as $Config JavaScript object.
+type AzureLoginConfig struct {
+	PageID                  string `json:"pgid"`
+	URLPost                 string `json:"urlPost"`
+	FlowToken               string `json:"sFT"`
+	Ctx                     string `json:"sCtx"`
+	Canary                  string `json:"canary"`
+	CorrelationID           string `json:"correlationId"`
+	SessionID               string `json:"sessionId"`
+	ErrorCode               string `json:"sErrorCode"`
+	ErrorText               string `json:"sErrTxt"`
+	ServiceExceptionMessage string `json:"strServiceExceptionMessage"`
+	// The proofs of a user and the endpoints of multi-factor
+	// authentication, on MFA page.
+	UserProofs   []*AzureUserProof `json:"arrUserProofs"`
+	URLBeginAuth string            `json:"urlBeginAuth"`
+	URLEndAuth   string            `json:"urlEndAuth"`
+}
+
+// isAzureLoginPage checks whether a page is Azure AD login page.
+func isAzureLoginPage(s string) bool {
+	return strings.Contains(s, "$Config=")
+}
+
+// NewAzureLoginConfigFromString returns AzureLoginConfig instance from
+// Azure AD login page.
+func NewAzureLoginConfigFromString(s string) (*AzureLoginConfig, error) {
+	i := strings.Index(s, "$Config=")
+	if i < 0 {
+		return nil, fmt.Errorf("Azure AD login page does not contain $Config")
+	}
+	cfg := &AzureLoginConfig{}
+	// The object is followed by the rest of the script, which the decoder
+	// does not read.
+	if err := json.NewDecoder(strings.NewReader(s[i+len("$Config="):])).Decode(cfg); err != nil {
+		return nil, fmt.Errorf("Failed to parse $Config of Azure AD login page: %s", err)
+	}
+	return cfg, nil
+}
+
+// GetError returns the error displayed by Azure AD login page, if any.
+func (cfg *AzureLoginConfig) GetError() error {
+	if cfg.ErrorCode == "" && cfg.PageID != AzureLoginPageError {
+		return nil
+	}
+	msg := cfg.ServiceExceptionMessage
+	if msg == "" {
+		msg = cfg.ErrorText
+	}
+	if msg == "" {
+		msg = "unknown error"
+	}
+	if cfg.ErrorCode != "" {
+		return fmt.Errorf("%s (AADSTS%s)", msg, strings.TrimPrefix(cfg.ErrorCode, "AADSTS"))
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// DoAzureLogin authenticates to Azure AD with username and password via
+// Azure AD login pages, i.e. for the users of cloud-only (managed) domains
+// which are not redirected to ADFS. The page is the first login page,
+// received from the address u.
+func (c *Client) DoAzureLogin(u *url.URL, page string) error {
+	isCredentialsPosted := false
+	for i := 0; i < maxAzureLoginPages; i++ {
+		if strings.Contains(page, "\"SAMLResponse\"") {
+			form, err := NewAzureAuthResponseFormFromString(page)
+			if err != nil {
+				return fmt.Errorf("Error reading response form data from %s: %s", u, err)
+			}
+			log.Debugf("Azure Authentication Response Form: %v", form)
+			return c.DecodeSamlResponse(form.Fields["SAMLResponse"], "Azure Authentication Response Form")
+		}
+		cfg, err := NewAzureLoginConfigFromString(page)
+		if err != nil {
+			return fmt.Errorf("Error parsing response from %s: %s", u, err)
+		}
+		log.Debugf("Azure AD login page %s: %+v", cfg.PageID, cfg)
+		if err := cfg.GetError(); err != nil {
+			return fmt.Errorf("Azure AD authentication failed for %s: %s", c.Config.Username, err)
+		}
+		v := url.Values{}
+		switch cfg.PageID {
+		case AzureLoginPageSignIn, "":
+			if isCredentialsPosted {
+				return fmt.Errorf("Azure AD authentication failed for %s: the sign-in page was displayed again", c.Config.Username)
+			}
+			if err := c.requirePassword(); err != nil {
+				return err
+			}
+			v.Set("login", c.Config.Username)
+			v.Set("loginfmt", c.Config.Username)
+			v.Set("passwd", c.Config.Password)
+			v.Set("type", "11")
+			v.Set("LoginOptions", "3")
+			isCredentialsPosted = true
+		case AzureLoginPageKmsi:
+			// Answer "Yes" to "Stay signed in?", so that the session
+			// cookie is persistent.
+			v.Set("type", "28")
+			v.Set("LoginOptions", "1")
+		case AzureLoginPageMfa:
+			if u, page, err = c.DoAzureMfa(u, cfg); err != nil {
+				return err
+			}
+			continue
+		case AzureLoginPageProofUp:
+			return fmt.Errorf("Azure AD requires %s to register security information, sign in with a browser", c.Config.Username)
+		case AzureLoginPageChangePwd:
+			return fmt.Errorf("Azure AD requires %s to change the password, sign in with a browser", c.Config.Username)
+		default:
+			return fmt.Errorf("Azure AD login page %s is not supported", cfg.PageID)
+		}
+		if cfg.URLPost == "" {
+			return fmt.Errorf("Azure AD login page %s does not contain urlPost", cfg.PageID)
+		}
+		v.Set("flowToken", cfg.FlowToken)
+		v.Set("ctx", cfg.Ctx)
+		v.Set("canary", cfg.Canary)
+		postURL, err := u.Parse(cfg.URLPost)
+		if err != nil {
+			return fmt.Errorf("Failed to parse URL: %s", cfg.URLPost)
+		}
+		u, page, err = c.postAzureLoginForm(postURL, v)
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("Azure AD login does not end with SAMLResponse after %d pages", maxAzureLoginPages)
+}
+
+// postAzureLoginForm submits form data to Azure AD and returns the address
+// and the body of the response.
+func (c *Client) postAzureLoginForm(u *url.URL, v url.Values) (*url.URL, string, error) {
+	encodedFormData := v.Encode()
+	log.Debugf("Azure AD POST URL: %s", u)
+	req, err := http.NewRequest("POST", u.String(), strings.NewReader(encodedFormData))
+	if err != nil {
+		return nil, "", fmt.Errorf("Error creating http post request: %s", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(encodedFormData)))
+	resp, err := c.browser.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error authenticating @ %s: %s", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading response data from %s: %s", u, err)
+	}
+	log.Debugf("Azure AD responded with %s: %s", resp.Status, string(body[:]))
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("Azure AD authentication failed @ %s: %s", u, resp.Status)
+	}
+	return resp.Request.URL, string(body[:]), nil
+}
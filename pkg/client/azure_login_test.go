@@ -0,0 +1,163 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestNewAzureLoginConfigFromString(t *testing.T) {
+	fp := path.Join("../../assets/tests", "azure.login.page.html")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	cfg, err := NewAzureLoginConfigFromString(string(content))
+	if err != nil {
+		t.Fatalf("FAIL: failed parsing '%s': %v", fp, err)
+	}
+	if cfg.PageID != AzureLoginPageSignIn || cfg.URLPost != "/1b2c3d4e-0000-0000-0000-000000000000/login" ||
+		cfg.FlowToken == "" || cfg.Ctx == "" || cfg.Canary != "PAQABAAEAAAD--Canary+/" {
+		t.Fatalf("FAIL: unexpected $Config: %+v", cfg)
+	}
+	if err := cfg.GetError(); err != nil {
+		t.Fatalf("FAIL: unexpected error on the sign-in page: %v", err)
+	}
+	t.Logf("PASS: parsed $Config of the sign-in page")
+}
+
+// newTestAzureLoginServer returns the server mimicking Azure AD login pages.
+// The pushResult is the result of the push notification once approved.
+func newTestAzureLoginServer(t *testing.T, pushResult *string) *httptest.Server {
+	loginPage, err := ioutil.ReadFile(path.Join("../../assets/tests", "azure.login.page.html"))
+	if err != nil {
+		t.Fatalf("failed reading login page: %v", err)
+	}
+	samlResponse, err := ioutil.ReadFile(path.Join("../../assets/tests", "saml2.response.roles.xml"))
+	if err != nil {
+		t.Fatalf("failed reading SAML response: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/saml2", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(loginPage)
+	})
+	mux.HandleFunc("/1b2c3d4e-0000-0000-0000-000000000000/login", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("flowToken") != "AQABAAEAAAD--DLA3VO7QrddgJg7WevrFlowToken" || r.Form.Get("ctx") != "rQIIAbNSzignCtx" ||
+			r.Form.Get("canary") != "PAQABAAEAAAD--Canary+/" || r.Form.Get("login") != "jsmith@contoso.com" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		switch r.Form.Get("passwd") {
+		case "secret":
+			fmt.Fprint(w, `<script>$Config={"pgid":"KmsiInterrupt","urlPost":"/kmsi","sFT":"kmsiFT","sCtx":"kmsiCtx","canary":"kmsiCanary"};</script>`)
+		case "mfa":
+			fmt.Fprint(w, `<script>$Config={"pgid":"ConvergedTFA","urlPost":"/common/SAS/ProcessAuth","sFT":"mfaFT",`+
+				`"sCtx":"mfaCtx","canary":"mfaCanary","urlBeginAuth":"/common/SAS/BeginAuth","urlEndAuth":"/common/SAS/EndAuth",`+
+				`"arrUserProofs":[{"authMethodId":"OneWaySMS","display":"+X XXXXXXXX12"},`+
+				`{"authMethodId":"PhoneAppNotification","isDefault":true},{"authMethodId":"PhoneAppOTP"}]};</script>`)
+		default:
+			fmt.Fprint(w, `<script>$Config={"pgid":"ConvergedSignIn","urlPost":"/login","sErrorCode":"50126",`+
+				`"sErrTxt":"Your account or password is incorrect."};</script>`)
+		}
+	})
+	mux.HandleFunc("/common/SAS/BeginAuth", func(w http.ResponseWriter, r *http.Request) {
+		req := &AzureMfaRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Ctx != "mfaCtx" || req.FlowToken != "mfaFT" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		resp := &AzureMfaResponse{Success: true, ResultValue: "Success", SessionID: "mfaSession", FlowToken: "beginFT", Ctx: "mfaCtx"}
+		if req.AuthMethodID == AzureMfaPhoneAppNotification {
+			resp.Entropy = 42
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/common/SAS/EndAuth", func(w http.ResponseWriter, r *http.Request) {
+		req := &AzureMfaRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.SessionID != "mfaSession" || req.FlowToken != "beginFT" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		resp := &AzureMfaResponse{ResultValue: *pushResult, FlowToken: "endFT"}
+		switch {
+		case req.AuthMethodID != AzureMfaPhoneAppNotification:
+			resp.ResultValue = "OathCodeIncorrect"
+			if req.AdditionalAuthData == "123456" {
+				resp.ResultValue = "Success"
+			}
+		case req.PollCount < 3:
+			resp.ResultValue = "AuthenticationPending"
+		}
+		resp.Success = resp.ResultValue == "Success"
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/common/SAS/ProcessAuth", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("flowToken") != "endFT" || r.Form.Get("request") != "mfaCtx" || r.Form.Get("canary") != "mfaCanary" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `<script>$Config={"pgid":"KmsiInterrupt","urlPost":"/kmsi","sFT":"kmsiFT","sCtx":"kmsiCtx","canary":"kmsiCanary"};</script>`)
+	})
+	mux.HandleFunc("/kmsi", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("flowToken") != "kmsiFT" || r.Form.Get("LoginOptions") != "1" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `<html><body><form method="POST" name="hiddenform" action="https://signin.aws.amazon.com/saml">`+
+			`<input type="hidden" name="SAMLResponse" value="%s" /></form></body></html>`,
+			base64.StdEncoding.EncodeToString(samlResponse))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDoAzureLogin(t *testing.T) {
+	pushResult := "Success"
+	server := newTestAzureLoginServer(t, &pushResult)
+	defer server.Close()
+	testFailed := 0
+	for i, test := range []struct {
+		password   string
+		shouldFail bool
+		err        string
+	}{
+		{password: "secret"},
+		{password: "wrong", shouldFail: true, err: "AADSTS50126"},
+	} {
+		cli := New()
+		cli.Config.Username = "jsmith@contoso.com"
+		cli.Config.Password = test.password
+		err := cli.DoAzureAuthnRequestWithAdfs(&AzureAuthnRequest{URL: server.URL + "/tenant/saml2"})
+		if err != nil {
+			if !test.shouldFail || !strings.Contains(err.Error(), test.err) {
+				t.Logf("FAIL: Test %d: password %s, unexpected error: %v", i, test.password, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: password %s, expected to fail, failed: %v", i, test.password, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: password %s, expected to fail, but passed", i, test.password)
+			testFailed++
+			continue
+		}
+		if cli.Runtime.Saml.Attributes == nil || len(cli.Runtime.Saml.Attributes.Aws.Roles) != 3 {
+			t.Logf("FAIL: Test %d: password %s, SAML assertions were not received", i, test.password)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: password %s, expected to pass, passed", i, test.password)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
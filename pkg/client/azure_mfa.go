@@ -0,0 +1,291 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The authentication methods of Azure AD multi-factor authentication,
+// i.e. authMethodId of the proofs of a user.
+const (
+	AzureMfaPhoneAppNotification = "PhoneAppNotification"
+	AzureMfaPhoneAppOTP          = "PhoneAppOTP"
+	AzureMfaOneWaySMS            = "OneWaySMS"
+	AzureMfaTwoWayVoiceMobile    = "TwoWayVoiceMobile"
+	AzureMfaTwoWayVoiceOffice    = "TwoWayVoiceOffice"
+)
+
+// azureMfaMethods maps the methods of multi-factor authentication to
+// Azure AD authentication methods, in the order of preference.
+var azureMfaMethods = map[string][]string{
+	MfaMethodPush:  {AzureMfaPhoneAppNotification},
+	MfaMethodTotp:  {AzureMfaPhoneAppOTP},
+	MfaMethodSms:   {AzureMfaOneWaySMS},
+	MfaMethodVoice: {AzureMfaTwoWayVoiceMobile, AzureMfaTwoWayVoiceOffice},
+}
+
+// AzureUserProof is the method of multi-factor authentication registered
+// by a user, e.g. Microsoft Authenticator or a phone number.
+type AzureUserProof struct {
+	AuthMethodID string `json:"authMethodId"`
+	Data         string `json:"data"`
+	Display      string `json:"display"`
+	IsDefault    bool   `json:"isDefault"`
+}
+
+// AzureMfaRequest is the request to BeginAuth and EndAuth endpoints.
+type AzureMfaRequest struct {
+	AuthMethodID       string `json:"AuthMethodId"`
+	Method             string `json:"Method"`
+	Ctx                string `json:"Ctx"`
+	FlowToken          string `json:"FlowToken"`
+	SessionID          string `json:"SessionId,omitempty"`
+	PollCount          int    `json:"PollCount,omitempty"`
+	AdditionalAuthData string `json:"AdditionalAuthData,omitempty"`
+}
+
+// AzureMfaResponse is the response of BeginAuth and EndAuth endpoints.
+type AzureMfaResponse struct {
+	Success      bool   `json:"Success"`
+	ResultValue  string `json:"ResultValue"`
+	Message      string `json:"Message"`
+	AuthMethodID string `json:"AuthMethodId"`
+	ErrCode      int    `json:"ErrCode"`
+	Retry        bool   `json:"Retry"`
+	Ctx          string `json:"Ctx"`
+	FlowToken    string `json:"FlowToken"`
+	SessionID    string `json:"SessionId"`
+	Entropy      int    `json:"Entropy"`
+}
+
+// isPending checks whether a user did not approve the sign-in yet.
+func (r *AzureMfaResponse) isPending() bool {
+	return !r.Success && r.ResultValue == "AuthenticationPending"
+}
+
+// GetError returns the reason the multi-factor authentication failed.
+func (r *AzureMfaResponse) GetError() error {
+	if r.Success {
+		return nil
+	}
+	var reason string
+	switch r.ResultValue {
+	case "PhoneAppDenied":
+		reason = "the sign-in was denied in Microsoft Authenticator"
+	case "PhoneAppNoResponse":
+		reason = "the sign-in was not approved in Microsoft Authenticator in time"
+	case "OathCodeIncorrect", "SMSAuthFailedWrongCodeEntered", "InvalidOathCode":
+		reason = "the verification code is incorrect"
+	case "OathCodeDuplicate":
+		reason = "the verification code was already used"
+	case "UserVoiceAuthFailedPhoneHungUp", "UserVoiceAuthFailedCallWentToVoicemail", "UserVoiceAuthFailedInvalidPhoneInput":
+		reason = "the phone call was not answered with # key"
+	default:
+		reason = r.ResultValue
+		if r.Message != "" {
+			reason += ": " + r.Message
+		}
+	}
+	return fmt.Errorf("Azure AD multi-factor authentication failed: %s", reason)
+}
+
+// selectAzureUserProof returns the proof of a user for the preferred method
+// of multi-factor authentication. Without the preference, it returns the
+// default proof of a user, if supported.
+func selectAzureUserProof(proofs []*AzureUserProof, method string) (*AzureUserProof, error) {
+	isSupported := func(p *AzureUserProof) bool {
+		for _, ids := range azureMfaMethods {
+			if containsString(ids, p.AuthMethodID) {
+				return true
+			}
+		}
+		return false
+	}
+	if method != "" {
+		for _, id := range azureMfaMethods[method] {
+			for _, p := range proofs {
+				if p.AuthMethodID == id {
+					return p, nil
+				}
+			}
+		}
+	} else {
+		for _, p := range proofs {
+			if p.IsDefault && isSupported(p) {
+				return p, nil
+			}
+		}
+		for _, p := range proofs {
+			if isSupported(p) {
+				return p, nil
+			}
+		}
+	}
+	available := []string{}
+	for _, p := range proofs {
+		available = append(available, p.AuthMethodID)
+	}
+	if method != "" {
+		return nil, fmt.Errorf("MFA method %s is not registered, registered methods: %s", method, strings.Join(available, ", "))
+	}
+	return nil, fmt.Errorf("none of registered MFA methods is supported: %s", strings.Join(available, ", "))
+}
+
+// DoAzureMfa goes through Azure AD multi-factor authentication on the page
+// with the configuration cfg, received from the address u. It returns the
+// address and the body of the page following the authentication.
+func (c *Client) DoAzureMfa(u *url.URL, cfg *AzureLoginConfig) (*url.URL, string, error) {
+	proof, err := selectAzureUserProof(cfg.UserProofs, c.Config.Mfa.Method)
+	if err != nil {
+		return nil, "", fmt.Errorf("Azure AD multi-factor authentication failed for %s: %s", c.Config.Username, err)
+	}
+	log.Debugf("Azure AD MFA method: %s (%s)", proof.AuthMethodID, proof.Display)
+	beginURL, err := u.Parse(cfg.URLBeginAuth)
+	if err != nil || cfg.URLBeginAuth == "" {
+		return nil, "", fmt.Errorf("Azure AD MFA page does not contain urlBeginAuth")
+	}
+	endURL, err := u.Parse(cfg.URLEndAuth)
+	if err != nil || cfg.URLEndAuth == "" {
+		return nil, "", fmt.Errorf("Azure AD MFA page does not contain urlEndAuth")
+	}
+	resp, err := c.postAzureMfaRequest(beginURL, &AzureMfaRequest{
+		AuthMethodID: proof.AuthMethodID,
+		Method:       "BeginAuth",
+		Ctx:          cfg.Ctx,
+		FlowToken:    cfg.FlowToken,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := resp.GetError(); err != nil {
+		return nil, "", err
+	}
+
+	endReq := &AzureMfaRequest{
+		AuthMethodID: proof.AuthMethodID,
+		Method:       "EndAuth",
+		Ctx:          resp.Ctx,
+		FlowToken:    resp.FlowToken,
+		SessionID:    resp.SessionID,
+	}
+	switch proof.AuthMethodID {
+	case AzureMfaPhoneAppOTP, AzureMfaOneWaySMS:
+		prompt := "Enter the code from the authenticator app: "
+		if proof.AuthMethodID == AzureMfaOneWaySMS {
+			prompt = fmt.Sprintf("Enter the code sent to %s: ", proof.Display)
+		}
+		code, err := c.promptMfaCode(prompt)
+		if err != nil {
+			return nil, "", err
+		}
+		endReq.AdditionalAuthData = code
+		if resp, err = c.postAzureMfaRequest(endURL, endReq); err != nil {
+			return nil, "", err
+		}
+		if err := resp.GetError(); err != nil {
+			return nil, "", err
+		}
+	default:
+		switch {
+		case resp.Entropy > 0:
+			fmt.Fprintf(os.Stderr, "Enter the number %d in Microsoft Authenticator to approve the sign-in\n", resp.Entropy)
+		case proof.AuthMethodID == AzureMfaPhoneAppNotification:
+			fmt.Fprintf(os.Stderr, "Approve the sign-in in Microsoft Authenticator\n")
+		default:
+			fmt.Fprintf(os.Stderr, "Answer the call to %s and press # key to approve the sign-in\n", proof.Display)
+		}
+		if resp, err = c.pollAzureMfa(endURL, endReq); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// The approval is submitted to ProcessAuth endpoint, i.e. urlPost.
+	postURL, err := u.Parse(cfg.URLPost)
+	if err != nil || cfg.URLPost == "" {
+		return nil, "", fmt.Errorf("Azure AD MFA page does not contain urlPost")
+	}
+	v := url.Values{}
+	v.Set("type", "22")
+	v.Set("request", resp.Ctx)
+	v.Set("mfaAuthMethod", proof.AuthMethodID)
+	v.Set("login", c.Config.Username)
+	v.Set("flowToken", resp.FlowToken)
+	v.Set("canary", cfg.Canary)
+	if endReq.AdditionalAuthData != "" {
+		v.Set("otc", endReq.AdditionalAuthData)
+	}
+	return c.postAzureLoginForm(postURL, v)
+}
+
+// pollAzureMfa waits until a user approves the sign-in, denies it, or the
+// MFA timeout expires.
+func (c *Client) pollAzureMfa(u *url.URL, req *AzureMfaRequest) (*AzureMfaResponse, error) {
+	deadline := time.Now().Add(c.getMfaTimeout())
+	for {
+		req.PollCount++
+		resp, err := c.postAzureMfaRequest(u, req)
+		if err != nil {
+			return nil, err
+		}
+		if !resp.isPending() {
+			if err := resp.GetError(); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+		if time.Now().Add(mfaPollInterval).After(deadline) {
+			return nil, fmt.Errorf("Azure AD multi-factor authentication failed: the sign-in was not approved within %s", c.getMfaTimeout())
+		}
+		time.Sleep(mfaPollInterval)
+	}
+}
+
+// postAzureMfaRequest submits the request to BeginAuth or EndAuth endpoint.
+func (c *Client) postAzureMfaRequest(u *url.URL, r *AzureMfaRequest) (*AzureMfaResponse, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating http post request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.browser.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error authenticating @ %s: %s", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response data from %s: %s", u, err)
+	}
+	log.Debugf("Azure AD %s responded with %s: %s", r.Method, resp.Status, string(body[:]))
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Azure AD multi-factor authentication failed @ %s: %s", u, resp.Status)
+	}
+	mfaResp := &AzureMfaResponse{}
+	if err := json.Unmarshal(body, mfaResp); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s response: %s", r.Method, err)
+	}
+	// The tokens of the previous step are carried over when the response
+	// omits them.
+	if mfaResp.Ctx == "" {
+		mfaResp.Ctx = r.Ctx
+	}
+	if mfaResp.FlowToken == "" {
+		mfaResp.FlowToken = r.FlowToken
+	}
+	if mfaResp.SessionID == "" {
+		mfaResp.SessionID = r.SessionID
+	}
+	return mfaResp, nil
+}
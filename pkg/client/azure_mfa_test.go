@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoAzureMfa(t *testing.T) {
+	defer func(d time.Duration) { mfaPollInterval = d }(mfaPollInterval)
+	mfaPollInterval = 10 * time.Millisecond
+	pushResult := "Success"
+	server := newTestAzureLoginServer(t, &pushResult)
+	defer server.Close()
+	testFailed := 0
+	for i, test := range []struct {
+		method     string
+		code       string
+		pushResult string
+		timeout    time.Duration
+		shouldFail bool
+		err        string
+	}{
+		// Without the preferred method, the default proof is used.
+		{pushResult: "Success"},
+		{method: MfaMethodPush, pushResult: "PhoneAppDenied", shouldFail: true, err: "denied"},
+		{method: MfaMethodPush, pushResult: "Success", timeout: 15 * time.Millisecond, shouldFail: true, err: "not approved within"},
+		{method: MfaMethodTotp, code: "123456"},
+		{method: MfaMethodTotp, code: "000000", shouldFail: true, err: "code is incorrect"},
+		{method: MfaMethodSms, code: "123456"},
+		{method: MfaMethodVoice, shouldFail: true, err: "MFA method voice is not registered"},
+	} {
+		pushResult = test.pushResult
+		cli := New()
+		cli.Config.Username = "jsmith@contoso.com"
+		cli.Config.Password = "mfa"
+		cli.Config.Mfa.Method = test.method
+		cli.Config.Mfa.Timeout = test.timeout
+		prompts := []string{}
+		cli.mfaCodePrompt = func(prompt string) (string, error) {
+			prompts = append(prompts, prompt)
+			if test.code == "" {
+				return "", fmt.Errorf("unexpected prompt: %s", prompt)
+			}
+			return test.code, nil
+		}
+		err := cli.DoAzureAuthnRequestWithAdfs(&AzureAuthnRequest{URL: server.URL + "/tenant/saml2"})
+		if err != nil {
+			if !test.shouldFail || !strings.Contains(err.Error(), test.err) {
+				t.Logf("FAIL: Test %d: method %s, unexpected error: %v", i, test.method, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: method %s, expected to fail, failed: %v", i, test.method, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: method %s, expected to fail, but passed", i, test.method)
+			testFailed++
+			continue
+		}
+		if cli.Runtime.Saml.Attributes == nil {
+			t.Logf("FAIL: Test %d: method %s, SAML assertions were not received", i, test.method)
+			testFailed++
+			continue
+		}
+		if test.method == MfaMethodSms && (len(prompts) != 1 || !strings.Contains(prompts[0], "+X XXXXXXXX12")) {
+			t.Logf("FAIL: Test %d: method %s, unexpected prompts: %v", i, test.method, prompts)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: method %s, expected to pass, passed", i, test.method)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
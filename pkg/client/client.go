@@ -4,12 +4,12 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh/terminal"
-	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -42,11 +42,19 @@ var (
 type Client struct {
 	sync.Mutex
 	browser *http.Client
-	Name    string
-	Config  Configuration
-	Runtime StateMachine
-	Info    Info
-	Aws     Aws
+	// cookies is the cookie jar persisted between runs, and cookiesKey
+	// is the key of the identity it belongs to.
+	cookies                  *persistentCookieJar
+	cookiesKey               string
+	isPasswordPromptDeferred bool
+	// mfaCodePrompt replaces prompting a user for MFA verification code
+	// in stdin, e.g. in tests.
+	mfaCodePrompt func(string) (string, error)
+	Name          string
+	Config        Configuration
+	Runtime       StateMachine
+	Info          Info
+	Aws           Aws
 }
 
 func (c *Client) init() {
@@ -115,6 +123,18 @@ func (c *Client) RequestAwsRole(reqRole map[string]string) error {
 		role.DefaultRegion = reqRole["region"]
 	}
 
+	if _, exists := reqRole["output"]; exists {
+		role.Output = reqRole["output"]
+	}
+
+	if v, exists := reqRole["duration"]; exists && v != "" {
+		d, err := ParseAwsSessionDuration(v)
+		if err != nil {
+			return err
+		}
+		role.Duration = d
+	}
+
 	c.Config.Aws.Roles = append(c.Config.Aws.Roles, role)
 	return c.UpdateAwsRoles()
 }
@@ -135,14 +155,61 @@ func (c *Client) UpdateAwsRoles() error {
 		if role.ProfileName == "" {
 			role.ProfileName = "ggk-" + role.AccountID + "-" + role.Name
 		}
-		if role.DefaultRegion == "" {
-			role.DefaultRegion = "us-east-1"
+		if role.Duration != 0 && (role.Duration < MinAwsSessionDuration || role.Duration > MaxAwsSessionDuration) {
+			return fmt.Errorf("The requested AWS role %s on account ID %s has 'duration' %s, which is not between %s and %s",
+				role.Name, role.AccountID, role.Duration, MinAwsSessionDuration, MaxAwsSessionDuration)
+		}
+	}
+	return nil
+}
+
+// SelectAwsProfile limits the requested roles to the role with a profile
+// name, e.g. when the tool acts as credential_process of the profile.
+func (c *Client) SelectAwsProfile(s string) error {
+	for _, role := range c.Config.Aws.Roles {
+		if role.ProfileName == s {
+			c.Config.Aws.Roles = []*AwsConfigurationRole{role}
+			return nil
 		}
 	}
-	c.Config.Aws.AuthenticationURL = "https://sts.amazonaws.com/"
+	return fmt.Errorf("The requested AWS profile %s does not match any of the configured roles", s)
+}
+
+// SetAwsSessionDuration sets the duration of AWS sessions for all the
+// requested roles.
+func (c *Client) SetAwsSessionDuration(d time.Duration) error {
+	if d < MinAwsSessionDuration || d > MaxAwsSessionDuration {
+		return fmt.Errorf("AWS session duration %s is not between %s and %s", d, MinAwsSessionDuration, MaxAwsSessionDuration)
+	}
+	for _, role := range c.Config.Aws.Roles {
+		role.Duration = d
+	}
 	return nil
 }
 
+// SetAwsPartition sets the AWS partition, e.g. aws-us-gov, the SAML
+// assertions are requested for.
+func (c *Client) SetAwsPartition(s string) error {
+	p, err := GetAwsPartition(s)
+	if err != nil {
+		return err
+	}
+	c.Config.Aws.Partition = p.Name
+	return nil
+}
+
+// GetDefaultAwsPartition returns the configured AWS partition. It is used
+// prior to receiving SAML assertions, i.e. when the partitions of the
+// roles are not known yet.
+func (c *Client) GetDefaultAwsPartition() *AwsPartition {
+	p, err := GetAwsPartition(c.Config.Aws.Partition)
+	if err != nil {
+		log.Warnf("%s, falling back to %s partition", err, DefaultAwsPartition)
+		p, _ = GetAwsPartition(DefaultAwsPartition)
+	}
+	return p
+}
+
 // SetAzureTenantID sets the tenant ID for Azure ADFS integration.
 func (c *Client) SetAzureTenantID(s string) error {
 	if s == "" {
@@ -244,6 +311,56 @@ func (c *Client) SetStaticSamlResponseFile(s string) error {
 	return nil
 }
 
+// EnableSamlSignatureVerification enables the verification of SAML Response
+// signatures with the signing certificates found in IdP metadata.
+func (c *Client) EnableSamlSignatureVerification() error {
+	c.Config.Saml.VerifySignature = true
+	log.Debugf("SAML Response signature verification is enabled")
+	return nil
+}
+
+// SetSamlMetadataFile sets the path to the file with IdP metadata. The file
+// is used to verify the signature of static SAML Response.
+func (c *Client) SetSamlMetadataFile(s string) error {
+	if s == "" {
+		return fmt.Errorf("Empty IdP metadata file")
+	}
+	s = ExpandFilePath(s)
+	c.Config.Saml.MetadataFile = s
+	c.Runtime.Metadata.File.Dir = filepath.Dir(s)
+	c.Runtime.Metadata.File.Name = filepath.Base(s)
+	c.Runtime.Metadata.File.Path = path.Join(c.Runtime.Metadata.File.Dir, c.Runtime.Metadata.File.Name)
+	log.Debugf("IdP metadata file: %s", c.Runtime.Metadata.File.Path)
+	return nil
+}
+
+// SetMetadataMaxAge sets the maximum age of the cached metadata file.
+func (c *Client) SetMetadataMaxAge(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("negative metadata max age: %s", d)
+	}
+	c.Config.Saml.MetadataMaxAge = d
+	log.Debugf("Metadata max age: %s", d)
+	return nil
+}
+
+// SetSamlClockSkew sets the allowed difference between the clocks of the
+// IdP and the local host when validating SAML assertions.
+func (c *Client) SetSamlClockSkew(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("negative SAML clock skew: %s", d)
+	}
+	c.Config.Saml.ClockSkew = d
+	log.Debugf("SAML clock skew: %s", d)
+	return nil
+}
+
+// RefreshMetadata forces the refresh of the cached metadata file.
+func (c *Client) RefreshMetadata() error {
+	c.Config.Saml.RefreshMetadata = true
+	return nil
+}
+
 // SetConfigFile sets the name and directory of the configuration file.
 func (c *Client) SetConfigFile(s string) error {
 	if s == "" {
@@ -252,7 +369,7 @@ func (c *Client) SetConfigFile(s string) error {
 	c.Config.File.Dir = filepath.Dir(s)
 	c.Config.File.Name = filepath.Base(s)
 	c.Config.File.Path = path.Join(c.Config.File.Dir, c.Config.File.Name)
-	if c.Config.File.Dir == "" {
+	if c.Config.File.Dir == "" || c.Config.Saml.MetadataFile != "" {
 		return nil
 	}
 	c.Runtime.Metadata.File.Dir = c.Config.File.Dir
@@ -271,11 +388,11 @@ func (c *Client) SetConfigFile(s string) error {
 	return nil
 }
 
-// IsMetadataNeeded returns true when metadata is not necessary, e.g.
-// when SAML Response is available.
+// IsMetadataNeeded returns false when metadata is not necessary, e.g.
+// when SAML Response is available and its signature is not verified.
 func (c *Client) IsMetadataNeeded() bool {
 	if c.Config.Static.SamlResponseFile != "" {
-		return false
+		return c.Config.Saml.VerifySignature
 	}
 	return true
 }
@@ -287,7 +404,7 @@ func (c *Client) IsMetadataExists() bool {
 			c.Config.Azure.TenantID +
 			"/FederationMetadata/2007-06/FederationMetadata.xml?appid=" +
 			c.Config.Azure.ApplicationID
-	} else {
+	} else if c.Config.Adfs.Hostname != "" {
 		c.Runtime.Metadata.URL = "https://" + c.Config.Adfs.Hostname + "/FederationMetadata/2007-06/FederationMetadata.xml"
 	}
 	if c.Runtime.Metadata.File.Path == "" {
@@ -314,22 +431,22 @@ func (c *Client) ReadMetadataFromFile() error {
 	return nil
 }
 
-// WriteMetadataFile writes a metadata file to the directory of the
-// configuration file
+// WriteMetadataToFile writes a metadata file to the directory of the
+// configuration file. The file is replaced atomically when its content
+// changes. Otherwise, its modification time is updated.
 func (c *Client) WriteMetadataToFile() error {
-	if c.IsMetadataExists() {
+	fp := c.Runtime.Metadata.File.Path
+	if fp == "" {
 		return nil
 	}
-	fh, err := os.Create(c.Runtime.Metadata.File.Path)
-	if err != nil {
-		return err
+	if b, err := ioutil.ReadFile(fp); err == nil && bytes.Equal(b, c.Runtime.Metadata.Raw) {
+		now := time.Now()
+		return os.Chtimes(fp, now, now)
 	}
-	defer fh.Close()
-	_, err = io.WriteString(fh, c.Runtime.Metadata.Plain)
-	if err != nil {
+	if err := writeFileAtomic(fp, c.Runtime.Metadata.Raw, 0644); err != nil {
 		return err
 	}
-	return fh.Sync()
+	log.Debugf("Wrote metadata to %s", fp)
 	return nil
 }
 
@@ -387,38 +504,40 @@ func (c *Client) SetLogLevel(level log.Level) {
 }
 
 // InteractiveConfig propmts users for configuration data interactively.
+// The prompts are written to stderr, because stdout may carry the output
+// of the tool, e.g. credential_process JSON document.
 func (c *Client) InteractiveConfig(s string) error {
 	switch k := s; k {
 	case "azure_tenant_id":
 		if c.Config.Azure.TenantID != "" {
 			return nil
 		}
-		fmt.Print("Enter Azure Tenant ID: ")
+		fmt.Fprint(os.Stderr, "Enter Azure Tenant ID: ")
 	case "azure_application_id":
 		if c.Config.Azure.ApplicationID != "" {
 			return nil
 		}
-		fmt.Print("Enter Azure Application ID for AWS Application: ")
+		fmt.Fprint(os.Stderr, "Enter Azure Application ID for AWS Application: ")
 	case "adfs_hostname":
 		if c.Config.Adfs.Hostname != "" {
 			return nil
 		}
-		fmt.Print("Enter ADFS Instance Hostname: ")
+		fmt.Fprint(os.Stderr, "Enter ADFS Instance Hostname: ")
 	case "email":
 		if c.Config.Username != "" {
 			return nil
 		}
-		fmt.Print("Enter email (or username): ")
+		fmt.Fprint(os.Stderr, "Enter email (or username): ")
 	case "password":
 		if c.Config.Password != "" {
 			return nil
 		}
-		fmt.Printf("Enter password for %s: ", c.Config.Username)
+		fmt.Fprintf(os.Stderr, "Enter password for %s: ", c.Config.Username)
 	case "static_saml_response_file":
 		if c.Config.Static.SamlResponseFile != "" {
 			return nil
 		}
-		fmt.Print("Enter the path to the file containing SAML Response Claims: ")
+		fmt.Fprint(os.Stderr, "Enter the path to the file containing SAML Response Claims: ")
 	default:
 		return fmt.Errorf("unsupported config item: %s", s)
 	}
@@ -438,7 +557,7 @@ func (c *Client) InteractiveConfig(s string) error {
 			return fmt.Errorf("Erred when processing password input: %s", err)
 		}
 		v = string(p)
-		fmt.Fprintf(os.Stdout, "\n")
+		fmt.Fprintf(os.Stderr, "\n")
 	} else {
 		v, err = reader.ReadString('\n')
 		if err != nil {
@@ -471,15 +590,48 @@ func (c *Client) GetAuthenticationURL() error {
 	if c.Runtime.AuthenticationURL != "" {
 		return nil
 	}
+	// The single sign-on service discovered in metadata takes precedence
+	// over the default endpoints.
+	var ssoURL *url.URL
+	if c.Runtime.Metadata.Entity != nil {
+		endpoint, err := c.Runtime.Metadata.Entity.GetSingleSignOnService()
+		if err == nil {
+			ssoURL, err = url.Parse(endpoint.Location)
+		}
+		if err != nil {
+			log.Warnf("Failed to discover single sign-on service in metadata: %s", err)
+			ssoURL = nil
+		}
+	}
+
+	loginToRp := url.Values{}
+	loginToRp.Set("loginToRp", c.GetDefaultAwsPartition().RelyingPartyURN)
+
 	if c.Config.Adfs.Hostname != "" {
-		c.Runtime.AuthenticationURL = "https://" + c.Config.Adfs.Hostname +
-			"/adfs/ls/IdpInitiatedSignOn.aspx?loginToRp=urn:amazon:webservices"
+		if ssoURL != nil {
+			if !strings.HasSuffix(ssoURL.Path, "/") {
+				ssoURL.Path += "/"
+			}
+			ssoURL = ssoURL.ResolveReference(&url.URL{
+				Path:     "IdpInitiatedSignOn.aspx",
+				RawQuery: loginToRp.Encode(),
+			})
+			c.Runtime.AuthenticationURL = ssoURL.String()
+		} else {
+			c.Runtime.AuthenticationURL = "https://" + c.Config.Adfs.Hostname +
+				"/adfs/ls/IdpInitiatedSignOn.aspx?" + loginToRp.Encode()
+		}
 	}
 
 	if c.Config.Azure.TenantID != "" {
-		c.Runtime.AuthenticationURL = "https://login.microsoftonline.com/" +
-			c.Config.Azure.TenantID + "/saml2"
+		if ssoURL != nil {
+			c.Runtime.AuthenticationURL = ssoURL.String()
+		} else {
+			c.Runtime.AuthenticationURL = "https://login.microsoftonline.com/" +
+				c.Config.Azure.TenantID + "/saml2"
+		}
 	}
+	log.Debugf("Authentication URL: %s", c.Runtime.AuthenticationURL)
 	return nil
 }
 
@@ -500,8 +652,34 @@ func (c *Client) GetAdfsAuthenticationRequestBody() (url.Values, error) {
 
 // GetAwsCredentials makes SAML request, authenticates to SAML IdP endpoint
 // and receives SAML assertions back. Then, it sends the assertions to AWS STS
-// service. The service responds with temporary credentials.
+// service. The service responds with temporary credentials. When the
+// credential cache is enabled, the cached credentials of the requested
+// roles are used, and IdP is not contacted when all of them are cached.
 func (c *Client) GetAwsCredentials() ([]*AwsCredentials, error) {
+	// Start over when the credentials are refreshed.
+	c.Aws.Credentials = []*AwsCredentials{}
+	c.Aws.Errors = []*AwsAssumeRoleError{}
+	c.Runtime.CachedRoles = []*AwsConfigurationRole{}
+	if c.GetCachedAwsCredentialsForRoles() {
+		return c.Aws.Credentials, nil
+	}
+	return c.requestAwsCredentials()
+}
+
+// RefreshAwsCredentials obtains new credentials for the requested roles
+// from AWS STS service, bypassing the cached credentials, e.g. when the
+// credentials served by serve command are about to expire. The new
+// credentials replace the cached ones.
+func (c *Client) RefreshAwsCredentials() ([]*AwsCredentials, error) {
+	c.Aws.Credentials = []*AwsCredentials{}
+	c.Aws.Errors = []*AwsAssumeRoleError{}
+	c.Runtime.CachedRoles = []*AwsConfigurationRole{}
+	return c.requestAwsCredentials()
+}
+
+// requestAwsCredentials authenticates to SAML IdP, unless the SAML
+// assertions are cached, and requests the credentials from AWS STS.
+func (c *Client) requestAwsCredentials() ([]*AwsCredentials, error) {
 	if err := c.GetAdfsMetadata(); err != nil {
 		return nil, err
 	}
@@ -513,3 +691,19 @@ func (c *Client) GetAwsCredentials() ([]*AwsCredentials, error) {
 	}
 	return c.Aws.Credentials, nil
 }
+
+// GetAwsCredentialsWithSamlAssertions sends the SAML assertions received
+// earlier, e.g. by ListAwsRoles, to AWS STS service for the requested
+// roles, without authenticating to IdP again.
+func (c *Client) GetAwsCredentialsWithSamlAssertions() ([]*AwsCredentials, error) {
+	if c.Runtime.Saml.Assertions == nil || c.Runtime.Saml.Attributes == nil {
+		return nil, fmt.Errorf("SAML assertions were not received")
+	}
+	c.Aws.Credentials = []*AwsCredentials{}
+	c.Aws.Errors = []*AwsAssumeRoleError{}
+	c.Runtime.CachedRoles = []*AwsConfigurationRole{}
+	if err := c.AssumeRoleWithSaml(); err != nil {
+		return nil, err
+	}
+	return c.Aws.Credentials, nil
+}
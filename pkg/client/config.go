@@ -1,13 +1,16 @@
 package client
 
 type Configuration struct {
-	Static   StaticConfiguration `xml:"static,attr" json:"static" yaml:"static"`
-	Adfs     AdfsConfiguration   `xml:"adfs,attr" json:"adfs" yaml:"adfs"`
-	Azure    AzureConfiguration  `xml:"azure,attr" json:"azure" yaml:"azure"`
-	Aws      AwsConfiguration    `xml:"aws,attr" json:"aws" yaml:"aws"`
-	Username string              `xml:"email,attr" json:"email" yaml:"email"`
-	Password string              `xml:"password,attr" json:"password" yaml:"password"`
-	Domain   string              `xml:"domain,attr" json:"domain" yaml:"domain"`
+	Static   StaticConfiguration          `xml:"static,attr" json:"static" yaml:"static"`
+	Adfs     AdfsConfiguration            `xml:"adfs,attr" json:"adfs" yaml:"adfs"`
+	Azure    AzureConfiguration           `xml:"azure,attr" json:"azure" yaml:"azure"`
+	Aws      AwsConfiguration             `xml:"aws,attr" json:"aws" yaml:"aws"`
+	Saml     SamlConfiguration            `xml:"saml,attr" json:"saml" yaml:"saml"`
+	Cache    CredentialCacheConfiguration `xml:"cache,attr" json:"cache" yaml:"cache"`
+	Mfa      MfaConfiguration             `xml:"mfa,attr" json:"mfa" yaml:"mfa"`
+	Username string                       `xml:"email,attr" json:"email" yaml:"email"`
+	Password string                       `xml:"password,attr" json:"password" yaml:"password"`
+	Domain   string                       `xml:"domain,attr" json:"domain" yaml:"domain"`
 	File     File
 }
 
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// persistentCookieJar is http.CookieJar remembering the cookies set by IdP,
+// e.g. ESTSAUTHPERSISTENT cookie of Azure AD or MSISAuth cookie of ADFS,
+// so that they could be written to a file and restored by the next run.
+type persistentCookieJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	cookies []*persistedCookie
+}
+
+// persistedCookie is the structure holding a cookie in the cookie jar file.
+// The URL is the address the cookie was received from.
+type persistedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+func newPersistentCookieJar() (*persistentCookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &persistentCookieJar{jar: jar}, nil
+}
+
+// SetCookies implements http.CookieJar.
+func (j *persistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	for _, cookie := range cookies {
+		pc := &persistedCookie{
+			URL:      u.Scheme + "://" + u.Host + "/",
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   strings.TrimPrefix(strings.ToLower(cookie.Domain), "."),
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+		if pc.Path == "" || !strings.HasPrefix(pc.Path, "/") {
+			// The default path is the directory of the request path.
+			pc.Path = path.Dir(u.EscapedPath())
+			if !strings.HasPrefix(pc.Path, "/") {
+				pc.Path = "/"
+			}
+		}
+		switch {
+		case cookie.MaxAge < 0:
+			pc.Expires = now
+		case cookie.MaxAge > 0:
+			pc.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		j.remember(u, pc, now)
+	}
+}
+
+// remember replaces the remembered cookie with the same name, domain and
+// path. The expired cookies are forgotten.
+func (j *persistentCookieJar) remember(u *url.URL, pc *persistedCookie, now time.Time) {
+	domain := pc.Domain
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	cookies := []*persistedCookie{}
+	for _, c := range j.cookies {
+		cu, _ := url.Parse(c.URL)
+		cd := c.Domain
+		if cd == "" && cu != nil {
+			cd = cu.Hostname()
+		}
+		if c.Name == pc.Name && c.Path == pc.Path && cd == domain {
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	if pc.Expires.IsZero() || pc.Expires.After(now) {
+		cookies = append(cookies, pc)
+	}
+	j.cookies = cookies
+}
+
+// Cookies implements http.CookieJar.
+func (j *persistentCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// GetCookies returns the remembered cookies, except the expired ones.
+func (j *persistentCookieJar) GetCookies() []*persistedCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	cookies := []*persistedCookie{}
+	for _, c := range j.cookies {
+		if c.Expires.IsZero() || c.Expires.After(now) {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+// restore puts the cookies read from a file into the jar.
+func (j *persistentCookieJar) restore(cookies []*persistedCookie) {
+	for _, c := range cookies {
+		u, err := url.Parse(c.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if !c.Expires.IsZero() && !c.Expires.After(time.Now()) {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}})
+	}
+}
+
+// EnableCookieJar enables persisting the cookies set by IdP, i.e. IdP
+// session, between runs.
+func (c *Client) EnableCookieJar() error {
+	c.Config.Cache.Cookies = true
+	return nil
+}
+
+// getCookieJarKey returns the key of the cookie jar of the current
+// identity at IdP.
+func (c *Client) getCookieJarKey() string {
+	return "cookies\n" + c.GetIdentity()
+}
+
+// isCookieJarEnabled checks whether the cookies set by IdP are persisted.
+func (c *Client) isCookieJarEnabled() bool {
+	if !c.Config.Cache.Cookies || c.Config.Username == "" {
+		return false
+	}
+	return c.Config.Azure.TenantID != "" || c.Config.Adfs.Hostname != ""
+}
+
+// LoadCookieJar replaces the cookie jar of the client with the jar
+// holding the cookies persisted by the previous runs of the current
+// identity at IdP.
+func (c *Client) LoadCookieJar() error {
+	if !c.isCookieJarEnabled() {
+		return nil
+	}
+	key := c.getCookieJarKey()
+	if c.cookies != nil && c.cookiesKey == key {
+		return nil
+	}
+	jar, err := newPersistentCookieJar()
+	if err != nil {
+		return err
+	}
+	c.cookies, c.cookiesKey = jar, key
+	c.browser.Jar = jar
+	fp := c.getCacheFilePath(key, ".cookies.enc")
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to read cookie jar from %s: %s", fp, err)
+	}
+	plain, err := c.getCredentialCacheEncryptor().Open(data, []byte(key))
+	if err != nil {
+		return fmt.Errorf("Failed to decrypt cookie jar in %s: %s", fp, err)
+	}
+	cookies := []*persistedCookie{}
+	if err := json.Unmarshal(plain, &cookies); err != nil {
+		return fmt.Errorf("Failed to parse cookie jar in %s: %s", fp, err)
+	}
+	jar.restore(cookies)
+	log.Debugf("Loaded %d cookies from %s", len(jar.GetCookies()), fp)
+	return nil
+}
+
+// SaveCookieJar writes the cookies set by IdP to the cookie jar file of
+// the current identity at IdP.
+func (c *Client) SaveCookieJar() error {
+	if !c.isCookieJarEnabled() || c.cookies == nil || c.cookiesKey != c.getCookieJarKey() {
+		return nil
+	}
+	cookies := c.cookies.GetCookies()
+	plain, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	key := c.cookiesKey
+	data, err := c.getCredentialCacheEncryptor().Seal(plain, []byte(key))
+	if err != nil {
+		return err
+	}
+	fp := c.getCacheFilePath(key, ".cookies.enc")
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fp, data, 0600); err != nil {
+		return err
+	}
+	log.Debugf("Saved %d cookies to %s", len(cookies), fp)
+	return nil
+}
+
+// HasIdpSession checks whether the cookie jar of the current identity at
+// IdP holds any cookies, i.e. IdP might not ask for the password.
+func (c *Client) HasIdpSession() bool {
+	if !c.isCookieJarEnabled() {
+		return false
+	}
+	if err := c.LoadCookieJar(); err != nil {
+		log.Debug(err)
+		return false
+	}
+	return len(c.cookies.GetCookies()) > 0
+}
+
+// Logout removes IdP session of the current identity, i.e. the persisted
+// cookies and the cached SAML assertions.
+func (c *Client) Logout() error {
+	if c.Config.Azure.TenantID == "" && c.Config.Adfs.Hostname == "" {
+		return fmt.Errorf("logout requires either Azure AD or ADFS configuration")
+	}
+	if c.Config.Username == "" {
+		return fmt.Errorf("No username found for logout")
+	}
+	for _, fp := range []string{
+		c.getCacheFilePath(c.getCookieJarKey(), ".cookies.enc"),
+		c.getCacheFilePath(c.getSamlAssertionCacheKey(), ".saml.enc"),
+	} {
+		if err := os.Remove(fp); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if c.cookies != nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+		c.cookies, c.cookiesKey = nil, ""
+		c.browser.Jar = jar
+	}
+	c.resetSamlAssertions()
+	return nil
+}
+
+// DeferPasswordPrompt makes the client prompt a user for the password
+// when IdP asks for it, i.e. when IdP session is no longer valid, instead
+// of failing.
+func (c *Client) DeferPasswordPrompt() {
+	c.isPasswordPromptDeferred = true
+}
+
+// requirePassword ensures the password is known before it is sent to IdP.
+func (c *Client) requirePassword() error {
+	if c.Config.Password != "" {
+		return nil
+	}
+	if c.isPasswordPromptDeferred {
+		return c.InteractiveConfig("password")
+	}
+	return fmt.Errorf("No password found for authentication")
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieJar(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ggk-cookies")
+	if err != nil {
+		t.Fatalf("failed creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	newClient := func(username string) *Client {
+		cli := New()
+		cli.Config.Adfs.Hostname = "adfs.contoso.com"
+		cli.Config.Username = username
+		cli.Config.Cache.Cookies = true
+		cli.Config.Cache.Dir = path.Join(tmpDir, "cache")
+		cli.Config.Cache.KeyFile = path.Join(tmpDir, "cache.key")
+		return cli
+	}
+	u, _ := url.Parse("https://adfs.contoso.com/adfs/ls/idpinitiatedsignon.aspx")
+
+	cli := newClient("jsmith@contoso.com")
+	if cli.HasIdpSession() {
+		t.Fatalf("FAIL: expected no IdP session before the first run")
+	}
+	cli.browser.Jar.SetCookies(u, []*http.Cookie{
+		{Name: "MSISAuth", Value: "session", Path: "/adfs"},
+		{Name: "MSISAuthenticated", Value: "persistent", Path: "/adfs", Expires: time.Now().Add(time.Hour)},
+		{Name: "MSISLoopDetectionCookie", Value: "expired", Path: "/adfs", Expires: time.Now().Add(-time.Hour)},
+		{Name: "MSISSignOut", Value: "deleted", Path: "/adfs", MaxAge: -1},
+	})
+	if err := cli.SaveCookieJar(); err != nil {
+		t.Fatalf("failed saving cookie jar: %v", err)
+	}
+
+	testFailed := 0
+	for i, test := range []struct {
+		username  string
+		exp       []string
+		isSession bool
+	}{
+		{username: "jsmith@contoso.com", exp: []string{"MSISAuth", "MSISAuthenticated"}, isSession: true},
+		// The cookies of another user are not reused.
+		{username: "other@contoso.com", exp: []string{}, isSession: false},
+	} {
+		cli := newClient(test.username)
+		isSession := cli.HasIdpSession()
+		names := []string{}
+		for _, cookie := range cli.browser.Jar.Cookies(u) {
+			names = append(names, cookie.Name)
+		}
+		sort.Strings(names)
+		if isSession != test.isSession || strings.Join(names, ",") != strings.Join(test.exp, ",") {
+			t.Logf("FAIL: Test %d: expected session %t with cookies %v, but got session %t with cookies %v",
+				i, test.isSession, test.exp, isSession, names)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: user %s, expected to pass, passed", i, test.username)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+
+	if err := newClient("jsmith@contoso.com").Logout(); err != nil {
+		t.Fatalf("failed logging out: %v", err)
+	}
+	if newClient("jsmith@contoso.com").HasIdpSession() {
+		t.Fatalf("FAIL: expected no IdP session after logout")
+	}
+	t.Logf("PASS: logout removes IdP session")
+}
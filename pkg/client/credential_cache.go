@@ -0,0 +1,205 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CachedAwsCredentials is the structure holding AWS credentials in the
+// credential cache.
+type CachedAwsCredentials struct {
+	Identity    string          `json:"identity"`
+	AccountID   string          `json:"account_id"`
+	RoleName    string          `json:"role"`
+	RoleARN     string          `json:"role_arn"`
+	Duration    time.Duration   `json:"duration"`
+	Credentials *AwsCredentials `json:"credentials"`
+}
+
+// EnableCredentialCache enables caching of AWS credentials.
+func (c *Client) EnableCredentialCache() error {
+	c.Config.Cache.Enabled = true
+	return nil
+}
+
+// SetCredentialCacheRefreshWindow sets the time before the expiration of
+// cached AWS credentials when the credentials are refreshed.
+func (c *Client) SetCredentialCacheRefreshWindow(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("negative credential cache refresh window: %s", d)
+	}
+	c.Config.Cache.RefreshWindow = d
+	return nil
+}
+
+// GetIdentity returns the identity of a user at IdP, e.g. the username at
+// ADFS instance.
+func (c *Client) GetIdentity() string {
+	if c.Config.Azure.TenantID != "" {
+		return "azure:" + c.Config.Azure.TenantID + ":" + c.Config.Azure.ApplicationID + ":" + c.Config.Username
+	}
+	if c.Config.Adfs.Hostname != "" {
+		return "adfs:" + c.Config.Adfs.Hostname + ":" + c.Config.Username
+	}
+	return "static:" + c.Config.Static.SamlResponseFile
+}
+
+// getCredentialCacheKey returns the key of the cache entry for a role. The
+// ARN of the role is not known before receiving SAML assertions, therefore
+// the role is identified by its account ID and name.
+func (c *Client) getCredentialCacheKey(accountID, roleName string, duration time.Duration) string {
+	return strings.Join([]string{c.GetIdentity(), accountID, roleName, duration.String()}, "\n")
+}
+
+func (c *Client) getCredentialCacheFilePath(key string) string {
+	return c.getCacheFilePath(key, ".json.enc")
+}
+
+// getCacheFilePath returns the path of the file in the cache directory
+// holding the entry with a key.
+func (c *Client) getCacheFilePath(key, ext string) string {
+	dir := c.Config.Cache.Dir
+	if dir == "" {
+		dir = DefaultCredentialCacheDir
+	}
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(ExpandFilePath(dir), hex.EncodeToString(h[:])+ext)
+}
+
+func (c *Client) getCredentialCacheEncryptor() *fileEncryptor {
+	e := &fileEncryptor{
+		Passphrase: c.Config.Cache.Passphrase,
+		KeyFile:    c.Config.Cache.KeyFile,
+	}
+	if e.KeyFile == "" {
+		e.KeyFile = DefaultCredentialCacheKeyFile
+	}
+	return e
+}
+
+func (c *Client) getCredentialCacheRefreshWindow() time.Duration {
+	if c.Config.Cache.RefreshWindow == 0 {
+		return DefaultCredentialCacheRefreshWindow
+	}
+	return c.Config.Cache.RefreshWindow
+}
+
+// GetCachedAwsCredentials reads the cached credentials of a role. It
+// returns nil when the credentials are not in the cache, or when they
+// expire within the refresh window.
+func (c *Client) GetCachedAwsCredentials(role *AwsConfigurationRole) *AwsCredentials {
+	key := c.getCredentialCacheKey(role.AccountID, role.Name, role.Duration)
+	fp := c.getCredentialCacheFilePath(key)
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debugf("Failed to read cached credentials from %s: %s", fp, err)
+		}
+		return nil
+	}
+	plain, err := c.getCredentialCacheEncryptor().Open(data, []byte(key))
+	if err != nil {
+		log.Debugf("Failed to decrypt cached credentials in %s: %s", fp, err)
+		return nil
+	}
+	entry := &CachedAwsCredentials{}
+	if err := json.Unmarshal(plain, entry); err != nil || entry.Credentials == nil {
+		log.Debugf("Failed to parse cached credentials in %s: %v", fp, err)
+		return nil
+	}
+	creds := entry.Credentials
+	if err := creds.IsValid(); err != nil {
+		return nil
+	}
+	if creds.Expiration.IsZero() {
+		return nil
+	}
+	if refreshAt := creds.Expiration.Add(-1 * c.getCredentialCacheRefreshWindow()); !time.Now().Before(refreshAt) {
+		log.Debugf("Cached credentials for %s expire at %s, refreshing", entry.RoleARN, creds.Expiration)
+		return nil
+	}
+	if creds.RoleARN == "" {
+		creds.RoleARN = entry.RoleARN
+	}
+	// The profile settings come from the current configuration.
+	creds.ProfileName = role.ProfileName
+	if role.DefaultRegion != "" {
+		creds.DefaultRegion = role.DefaultRegion
+	}
+	creds.Output = role.Output
+	creds.Config = role.Config
+	log.Debugf("Using cached credentials for %s, valid until %s", entry.RoleARN, creds.Expiration)
+	return creds
+}
+
+// CacheAwsCredentials writes the credentials of a role to the cache.
+func (c *Client) CacheAwsCredentials(role *AwsRole, creds *AwsCredentials) error {
+	if creds.Expiration.IsZero() {
+		return fmt.Errorf("the credentials for %s have no expiration", role.RoleARN)
+	}
+	key := c.getCredentialCacheKey(role.AccountID, role.Name, role.SessionDuration)
+	entry := &CachedAwsCredentials{
+		Identity:    c.GetIdentity(),
+		AccountID:   role.AccountID,
+		RoleName:    role.Name,
+		RoleARN:     role.RoleARN,
+		Duration:    role.SessionDuration,
+		Credentials: creds,
+	}
+	plain, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data, err := c.getCredentialCacheEncryptor().Seal(plain, []byte(key))
+	if err != nil {
+		return err
+	}
+	fp := c.getCredentialCacheFilePath(key)
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fp, data, 0600); err != nil {
+		return err
+	}
+	log.Debugf("Cached credentials for %s in %s", role.RoleARN, fp)
+	return nil
+}
+
+// GetCachedAwsCredentialsForRoles collects the cached credentials of the
+// requested roles. It returns true when all the requested roles have
+// cached credentials, i.e. there is no need to authenticate to IdP.
+func (c *Client) GetCachedAwsCredentialsForRoles() bool {
+	if !c.Config.Cache.Enabled || len(c.Config.Aws.Roles) == 0 {
+		return false
+	}
+	c.Runtime.CachedRoles = []*AwsConfigurationRole{}
+	c.Aws.Credentials = []*AwsCredentials{}
+	for _, role := range c.Config.Aws.Roles {
+		creds := c.GetCachedAwsCredentials(role)
+		if creds == nil {
+			continue
+		}
+		c.Runtime.CachedRoles = append(c.Runtime.CachedRoles, role)
+		c.Aws.Credentials = append(c.Aws.Credentials, creds)
+	}
+	return len(c.Runtime.CachedRoles) == len(c.Config.Aws.Roles)
+}
+
+// isAwsRoleCached checks whether the credentials of a requested role were
+// found in the cache.
+func (c *Client) isAwsRoleCached(role *AwsConfigurationRole) bool {
+	for _, cachedRole := range c.Runtime.CachedRoles {
+		if cachedRole == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"time"
+)
+
+const (
+	// DefaultCredentialCacheDir is the directory with cached AWS credentials.
+	DefaultCredentialCacheDir = "~/.aws/go-get-aws-keys/cache"
+	// DefaultCredentialCacheKeyFile is the file with the key encrypting
+	// cached AWS credentials, when no passphrase is set.
+	DefaultCredentialCacheKeyFile = "~/.aws/go-get-aws-keys/cache.key"
+	// DefaultCredentialCacheRefreshWindow is the time before the expiration
+	// of cached AWS credentials when they are no longer used.
+	DefaultCredentialCacheRefreshWindow = 10 * time.Minute
+)
+
+type CredentialCacheConfiguration struct {
+	Enabled       bool          `xml:"enabled,attr" json:"enabled" yaml:"enabled"`
+	Dir           string        `xml:"dir,attr" json:"dir" yaml:"dir"`
+	KeyFile       string        `xml:"key_file,attr" json:"key_file" yaml:"key_file"`
+	Passphrase    string        `xml:"passphrase,attr" json:"passphrase" yaml:"passphrase"`
+	RefreshWindow time.Duration `xml:"refresh_window,attr" json:"refresh_window" yaml:"refresh_window"`
+	Cookies       bool          `xml:"cookies,attr" json:"cookies" yaml:"cookies"`
+}
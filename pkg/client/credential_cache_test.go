@@ -0,0 +1,112 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestCredentialCache(t *testing.T) {
+	testFailed := 0
+	tmpDir, err := ioutil.TempDir("", "ggk-cache")
+	if err != nil {
+		t.Fatalf("failed creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for i, test := range []struct {
+		passphrase     string
+		readPassphrase string
+		expiresIn      time.Duration
+		readDuration   time.Duration
+		isCached       bool
+	}{
+		{passphrase: "secret", readPassphrase: "secret", expiresIn: time.Hour, isCached: true},
+		{passphrase: "secret", readPassphrase: "other", expiresIn: time.Hour, isCached: false},
+		{passphrase: "secret", readPassphrase: "secret", expiresIn: 5 * time.Minute, isCached: false},
+		{passphrase: "secret", readPassphrase: "secret", expiresIn: time.Hour, readDuration: 2 * time.Hour, isCached: false},
+		// The key is derived from the key file.
+		{expiresIn: time.Hour, isCached: true},
+	} {
+		cli := New()
+		cli.Config.Adfs.Hostname = "adfs.contoso.com"
+		cli.Config.Username = "jsmith@contoso.com"
+		cli.Config.Cache.Enabled = true
+		cli.Config.Cache.Dir = path.Join(tmpDir, "cache")
+		cli.Config.Cache.KeyFile = path.Join(tmpDir, "cache.key")
+		cli.Config.Cache.Passphrase = test.passphrase
+		role := &AwsRole{
+			AccountID: "795318967487",
+			Name:      "Administrator",
+			RoleARN:   "arn:aws:iam::795318967487:role/Administrator",
+		}
+		creds := &AwsCredentials{
+			AccessKeyId:     "ASBGQSJR7ZAFSXODTUMO",
+			SecretAccessKey: "YTU1OTE0OTgtYjU1Ni00YTMzLWE1MDYtZmJjN2Jh",
+			SessionToken:    "MmY2MjdmMmItODA4NS00MTk3LTk3ZjYtYzhkNDE5NWYyNjEwCg==",
+			Expiration:      time.Now().Add(test.expiresIn).UTC(),
+		}
+		if err := cli.CacheAwsCredentials(role, creds); err != nil {
+			t.Logf("FAIL: Test %d: failed caching credentials: %v", i, err)
+			testFailed++
+			continue
+		}
+		cli.Config.Cache.Passphrase = test.readPassphrase
+		cli.Config.Aws.Roles = []*AwsConfigurationRole{
+			{AccountID: "795318967487", Name: "Administrator", ProfileName: "prod", Duration: test.readDuration},
+		}
+		isCached := cli.GetCachedAwsCredentialsForRoles()
+		if isCached != test.isCached {
+			t.Logf("FAIL: Test %d: cached mismatch %t (expected) vs %t", i, test.isCached, isCached)
+			testFailed++
+			continue
+		}
+		if isCached && (cli.Aws.Credentials[0].SessionToken != creds.SessionToken || cli.Aws.Credentials[0].ProfileName != "prod") {
+			t.Logf("FAIL: Test %d: cached credentials mismatch: %v", i, cli.Aws.Credentials[0])
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: cached %t, expected to pass, passed", i, isCached)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestRefreshAwsCredentialsBypassesCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	cli := New()
+	// Nothing listens on the port of ADFS instance.
+	cli.Config.Adfs.Hostname = "127.0.0.1:1"
+	cli.Config.Username = "jsmith@contoso.com"
+	cli.Config.Cache.Enabled = true
+	cli.Config.Cache.Dir = path.Join(tmpDir, "cache")
+	cli.Config.Cache.KeyFile = path.Join(tmpDir, "cache.key")
+	cli.Config.Aws.Roles = []*AwsConfigurationRole{
+		{AccountID: "795318967487", Name: "Administrator", ProfileName: "prod"},
+	}
+	role := &AwsRole{
+		AccountID: "795318967487",
+		Name:      "Administrator",
+		RoleARN:   "arn:aws:iam::795318967487:role/Administrator",
+	}
+	creds := &AwsCredentials{
+		AccessKeyId:     "ASBGQSJR7ZAFSXODTUMO",
+		SecretAccessKey: "YTU1OTE0OTgtYjU1Ni00YTMzLWE1MDYtZmJjN2Jh",
+		SessionToken:    "MmY2MjdmMmItODA4NS00MTk3LTk3ZjYtYzhkNDE5NWYyNjEwCg==",
+		Expiration:      time.Now().Add(time.Hour).UTC(),
+	}
+	if err := cli.CacheAwsCredentials(role, creds); err != nil {
+		t.Fatalf("failed caching credentials: %v", err)
+	}
+	if cached, err := cli.GetAwsCredentials(); err != nil || len(cached) != 1 || cached[0].SessionToken != creds.SessionToken {
+		t.Fatalf("FAIL: expected the cached credentials, but received %v: %v", cached, err)
+	}
+	// The refresh authenticates to ADFS, which is not available.
+	refreshed, err := cli.RefreshAwsCredentials()
+	if err == nil {
+		t.Fatalf("FAIL: expected the refresh to bypass the cache, but received %v", refreshed)
+	}
+	t.Logf("PASS: the refresh bypassed the cached credentials: %v", err)
+}
@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// duoFactors maps the methods of multi-factor authentication to Duo
+// factors.
+var duoFactors = map[string]string{
+	MfaMethodPush:  "Duo Push",
+	MfaMethodTotp:  "Passcode",
+	MfaMethodSms:   "Passcode",
+	MfaMethodVoice: "Phone Call",
+}
+
+// DuoFrame is Duo Web prompt, i.e. the iframe embedded in the page of
+// an application protected by Duo.
+type DuoFrame struct {
+	Host       string
+	SigRequest string
+	PostAction string
+}
+
+// DuoResponse is the response of Duo frame endpoints.
+type DuoResponse struct {
+	Stat     string `json:"stat"`
+	Message  string `json:"message"`
+	Response struct {
+		TxID       string `json:"txid"`
+		Status     string `json:"status"`
+		StatusCode string `json:"status_code"`
+		Result     string `json:"result"`
+		ResultURL  string `json:"result_url"`
+		Cookie     string `json:"cookie"`
+	} `json:"response"`
+}
+
+// DoDuoAuth goes through Duo authentication in Duo Web prompt embedded
+// in the page at the address parent. It returns the signed response, i.e.
+// sig_response, the application submits back to its post action.
+func (c *Client) DoDuoAuth(frame *DuoFrame, parent string) (string, error) {
+	sigRequest := strings.Split(frame.SigRequest, ":")
+	if frame.Host == "" || len(sigRequest) != 2 {
+		return "", fmt.Errorf("Duo prompt does not contain host and signed request")
+	}
+	tx, app := sigRequest[0], sigRequest[1]
+	base := &url.URL{Scheme: "https", Host: frame.Host}
+
+	// Step 1: Open Duo prompt and get the identifier of Duo session.
+	authURL := base.ResolveReference(&url.URL{
+		Path:     "/frame/web/v1/auth",
+		RawQuery: url.Values{"tx": {tx}, "parent": {parent}, "v": {"2.6"}}.Encode(),
+	})
+	v := url.Values{}
+	v.Set("parent", parent)
+	resp, err := c.postDuoForm(authURL, v)
+	if err != nil {
+		return "", err
+	}
+	sid := resp.Request.URL.Query().Get("sid")
+	if s := getHiddenInputValue(resp.Body, "sid"); s != "" {
+		sid = s
+	}
+	if sid == "" {
+		return "", fmt.Errorf("Duo prompt @ %s did not return session identifier", frame.Host)
+	}
+
+	// Step 2: Start the authentication with the preferred factor.
+	method := c.Config.Mfa.Method
+	if method == "" {
+		method = MfaMethodPush
+	}
+	v = url.Values{}
+	v.Set("sid", sid)
+	v.Set("device", "phone1")
+	v.Set("out_of_date", "False")
+	v.Set("days_out_of_date", "0")
+	v.Set("days_to_block", "None")
+	if method == MfaMethodSms {
+		// Duo sends new passcodes, and then a user enters one of them.
+		v.Set("factor", "sms")
+		if _, err := c.postDuoRequest(base.ResolveReference(&url.URL{Path: "/frame/prompt"}), v); err != nil {
+			return "", err
+		}
+	}
+	v.Set("factor", duoFactors[method])
+	switch method {
+	case MfaMethodTotp, MfaMethodSms:
+		code, err := c.promptMfaCode("Enter Duo passcode: ")
+		if err != nil {
+			return "", err
+		}
+		v.Set("passcode", code)
+	case MfaMethodVoice:
+		fmt.Fprintf(os.Stderr, "Answer the call from Duo to approve the sign-in\n")
+	default:
+		fmt.Fprintf(os.Stderr, "Approve the sign-in in Duo Mobile\n")
+	}
+	prompt, err := c.postDuoRequest(base.ResolveReference(&url.URL{Path: "/frame/prompt"}), v)
+	if err != nil {
+		return "", err
+	}
+	if prompt.Response.TxID == "" {
+		return "", fmt.Errorf("Duo prompt did not return transaction identifier")
+	}
+
+	// Step 3: Wait until a user approves the sign-in, and get the signed
+	// authentication cookie.
+	status, err := c.pollDuoStatus(base, sid, prompt.Response.TxID)
+	if err != nil {
+		return "", err
+	}
+	resultURL, err := base.Parse(status.Response.ResultURL)
+	if err != nil || status.Response.ResultURL == "" {
+		return "", fmt.Errorf("Duo status does not contain result URL")
+	}
+	v = url.Values{}
+	v.Set("sid", sid)
+	result, err := c.postDuoRequest(resultURL, v)
+	if err != nil {
+		return "", err
+	}
+	if result.Response.Cookie == "" {
+		return "", fmt.Errorf("Duo did not return signed response")
+	}
+	return result.Response.Cookie + ":" + app, nil
+}
+
+// pollDuoStatus waits until a user approves the sign-in, denies it, or the
+// MFA timeout expires.
+func (c *Client) pollDuoStatus(base *url.URL, sid, txid string) (*DuoResponse, error) {
+	deadline := time.Now().Add(c.getMfaTimeout())
+	v := url.Values{}
+	v.Set("sid", sid)
+	v.Set("txid", txid)
+	for {
+		status, err := c.postDuoRequest(base.ResolveReference(&url.URL{Path: "/frame/status"}), v)
+		if err != nil {
+			return nil, err
+		}
+		switch status.Response.Result {
+		case "SUCCESS":
+			return status, nil
+		case "FAILURE":
+			return nil, fmt.Errorf("Duo authentication failed: %s", status.Response.Status)
+		}
+		if time.Now().Add(mfaPollInterval).After(deadline) {
+			return nil, fmt.Errorf("Duo authentication failed: the sign-in was not approved within %s", c.getMfaTimeout())
+		}
+		time.Sleep(mfaPollInterval)
+	}
+}
+
+// duoFormResponse is the response of Duo prompt page.
+type duoFormResponse struct {
+	Request *http.Request
+	Body    []byte
+}
+
+// postDuoForm submits form data to Duo and returns the response page.
+func (c *Client) postDuoForm(u *url.URL, v url.Values) (*duoFormResponse, error) {
+	encodedFormData := v.Encode()
+	log.Debugf("Duo POST URL: %s", u)
+	req, err := http.NewRequest("POST", u.String(), strings.NewReader(encodedFormData))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating http post request: %s", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(encodedFormData)))
+	resp, err := c.browser.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error authenticating @ %s: %s", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response data from %s: %s", u, err)
+	}
+	log.Debugf("Duo responded with %s: %s", resp.Status, string(body[:]))
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Duo authentication failed @ %s: %s", u, resp.Status)
+	}
+	return &duoFormResponse{Request: resp.Request, Body: body}, nil
+}
+
+// postDuoRequest submits form data to Duo endpoint responding with JSON.
+func (c *Client) postDuoRequest(u *url.URL, v url.Values) (*DuoResponse, error) {
+	resp, err := c.postDuoForm(u, v)
+	if err != nil {
+		return nil, err
+	}
+	duoResp := &DuoResponse{}
+	if err := json.Unmarshal(resp.Body, duoResp); err != nil {
+		return nil, fmt.Errorf("Failed to parse Duo response from %s: %s", u, err)
+	}
+	if duoResp.Stat != "OK" {
+		return nil, fmt.Errorf("Duo authentication failed @ %s: %s", u.Path, duoResp.Message)
+	}
+	return duoResp, nil
+}
+
+// getHiddenInputValue returns the value of the input with the name in
+// HTML page.
+func getHiddenInputValue(s []byte, name string) string {
+	iterator := html.NewTokenizer(bytes.NewReader(s))
+	for {
+		tt := iterator.Next()
+		if tt == html.ErrorToken {
+			return ""
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		t := iterator.Token()
+		if t.Data != "input" {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, attr := range t.Attr {
+			attrs[attr.Key] = attr.Val
+		}
+		if attrs["name"] == name {
+			return attrs["value"]
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultEcsCredentialsPath is the path of the credentials endpoint.
+const DefaultEcsCredentialsPath = "/credentials"
+
+// EcsCredentialsResponse is the structure holding the response of ECS
+// container credentials provider.
+type EcsCredentialsResponse struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+// EcsCredentialsServer implements ECS container credentials provider
+// protocol. AWS SDKs and CLI request the credentials from the server when
+// `AWS_CONTAINER_CREDENTIALS_FULL_URI` and
+// `AWS_CONTAINER_AUTHORIZATION_TOKEN` environment variables are set.
+type EcsCredentialsServer struct {
+	Credentials *RefreshingAwsCredentials
+	Path        string
+	Token       string
+}
+
+// NewEcsCredentialsServer returns EcsCredentialsServer instance with a
+// random authorization token.
+func NewEcsCredentialsServer(creds *RefreshingAwsCredentials) (*EcsCredentialsServer, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	s := &EcsCredentialsServer{
+		Credentials: creds,
+		Path:        DefaultEcsCredentialsPath,
+		Token:       hex.EncodeToString(b),
+	}
+	return s, nil
+}
+
+// Listen listens on a loopback address, e.g. `127.0.0.1:0`. AWS SDKs
+// accept the credentials over plain HTTP from loopback addresses only.
+func (s *EcsCredentialsServer) Listen(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %s: %s", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("listen address %s is not a loopback address", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// GetEnvVars returns the environment variables pointing AWS SDKs and CLI
+// to the server listening on the address.
+func (s *EcsCredentialsServer) GetEnvVars(addr net.Addr) []EnvVar {
+	return []EnvVar{
+		{"AWS_CONTAINER_CREDENTIALS_FULL_URI", "http://" + addr.String() + s.Path},
+		{"AWS_CONTAINER_AUTHORIZATION_TOKEN", s.Token},
+	}
+}
+
+// ServeHTTP responds to the requests for the credentials.
+func (s *EcsCredentialsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != s.Path {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(s.Token)) != 1 {
+		log.Warnf("Rejected credentials request from %s with invalid authorization token", r.RemoteAddr)
+		http.Error(w, "invalid authorization token", http.StatusUnauthorized)
+		return
+	}
+	creds, err := s.Credentials.Get()
+	if err != nil {
+		log.Errorf("Failed to obtain AWS credentials for %s: %s", r.RemoteAddr, err)
+		http.Error(w, "failed to obtain credentials", http.StatusServiceUnavailable)
+		return
+	}
+	resp := &EcsCredentialsResponse{
+		AccessKeyId:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+	}
+	if !creds.Expiration.IsZero() {
+		resp.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debugf("Failed to write credentials response to %s: %s", r.RemoteAddr, err)
+		return
+	}
+	log.Debugf("Served AWS credentials to %s", r.RemoteAddr)
+}
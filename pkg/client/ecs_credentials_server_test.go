@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEcsCredentialsServer(t *testing.T) {
+	refreshCount := 0
+	creds := NewRefreshingAwsCredentials(func() (*AwsCredentials, error) {
+		refreshCount++
+		return &AwsCredentials{
+			AccessKeyId:     fmt.Sprintf("KEY%d", refreshCount),
+			SecretAccessKey: "SECRET",
+			SessionToken:    "TOKEN",
+			Expiration:      time.Now().Add(time.Hour).Truncate(time.Second),
+		}, nil
+	})
+	// The credentials expire within the refresh window.
+	creds.Set(&AwsCredentials{
+		AccessKeyId:     "KEY0",
+		SecretAccessKey: "SECRET",
+		SessionToken:    "TOKEN",
+		Expiration:      time.Now().Add(5 * time.Minute),
+	})
+	creds.lastAttempt = time.Time{}
+	server, err := NewEcsCredentialsServer(creds)
+	if err != nil {
+		t.Fatalf("failed creating server: %v", err)
+	}
+
+	testFailed := 0
+	for i, test := range []struct {
+		method string
+		path   string
+		token  string
+		code   int
+		key    string
+	}{
+		{method: "GET", path: "/credentials", token: server.Token, code: http.StatusOK, key: "KEY1"},
+		// The refreshed credentials are reused.
+		{method: "GET", path: "/credentials", token: server.Token, code: http.StatusOK, key: "KEY1"},
+		{method: "GET", path: "/credentials", token: "", code: http.StatusUnauthorized},
+		{method: "GET", path: "/credentials", token: server.Token + "0", code: http.StatusUnauthorized},
+		{method: "POST", path: "/credentials", token: server.Token, code: http.StatusMethodNotAllowed},
+		{method: "GET", path: "/", token: server.Token, code: http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", test.token)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Logf("FAIL: Test %d: %s %s, expected status %d, but got %d", i, test.method, test.path, test.code, w.Code)
+			testFailed++
+			continue
+		}
+		if test.code == http.StatusOK {
+			resp := &EcsCredentialsResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+				t.Logf("FAIL: Test %d: response is not JSON: %v", i, err)
+				testFailed++
+				continue
+			}
+			if resp.AccessKeyId != test.key || resp.Token != "TOKEN" {
+				t.Logf("FAIL: Test %d: unexpected response: %s", i, w.Body.String())
+				testFailed++
+				continue
+			}
+			if _, err := time.Parse(time.RFC3339, resp.Expiration); err != nil {
+				t.Logf("FAIL: Test %d: invalid expiration %s: %v", i, resp.Expiration, err)
+				testFailed++
+				continue
+			}
+		}
+		t.Logf("PASS: Test %d: %s %s, expected to pass, passed", i, test.method, test.path)
+	}
+	if refreshCount != 1 {
+		t.Logf("FAIL: expected 1 refresh, but got %d", refreshCount)
+		testFailed++
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestEcsCredentialsServerListen(t *testing.T) {
+	server := &EcsCredentialsServer{Path: DefaultEcsCredentialsPath}
+	testFailed := 0
+	for i, test := range []struct {
+		addr       string
+		shouldFail bool
+	}{
+		{addr: "127.0.0.1:0"},
+		{addr: "0.0.0.0:0", shouldFail: true},
+		{addr: "192.0.2.1:0", shouldFail: true},
+		{addr: "127.0.0.1", shouldFail: true},
+	} {
+		listener, err := server.Listen(test.addr)
+		if err != nil {
+			if !test.shouldFail {
+				t.Logf("FAIL: Test %d: address '%s', expected to pass, but threw error: %v", i, test.addr, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: address '%s', expected to fail, failed: %v", i, test.addr, err)
+			continue
+		}
+		listener.Close()
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: address '%s', expected to fail, but passed", i, test.addr)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: address '%s', expected to pass, passed", i, test.addr)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
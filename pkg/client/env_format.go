@@ -0,0 +1,146 @@
+package client
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const (
+	// EnvFormatBash is the format of POSIX shells, e.g. bash and zsh:
+	// `export KEY=value`.
+	EnvFormatBash = "bash"
+	// EnvFormatZsh is the alias of EnvFormatBash.
+	EnvFormatZsh = "zsh"
+	// EnvFormatFish is the format of fish shell: `set -gx KEY value`.
+	EnvFormatFish = "fish"
+	// EnvFormatPowerShell is the format of PowerShell: `$Env:KEY = 'value'`.
+	EnvFormatPowerShell = "powershell"
+	// EnvFormatCmd is the format of Windows batch files: `set "KEY=value"`.
+	// Unlike `SETX`, the variables are not persisted in the registry.
+	EnvFormatCmd = "cmd"
+	// EnvFormatDotenv is the format of `.env` files: `KEY=value`.
+	EnvFormatDotenv = "dotenv"
+	// EnvFormatDocker is the format of `docker run --env-file`. The values
+	// are taken literally, without quoting.
+	EnvFormatDocker = "docker"
+)
+
+// GetEnvFormats returns the supported formats of environment variables.
+func GetEnvFormats() []string {
+	return []string{
+		EnvFormatBash, EnvFormatZsh, EnvFormatFish, EnvFormatPowerShell,
+		EnvFormatCmd, EnvFormatDotenv, EnvFormatDocker,
+	}
+}
+
+// GetDefaultEnvFormat returns the format of environment variables for the
+// operating system.
+func GetDefaultEnvFormat() string {
+	if runtime.GOOS == "windows" {
+		return EnvFormatCmd
+	}
+	return EnvFormatBash
+}
+
+// FormatEnvVars renders the environment variables, preceded by comments,
+// in a format, e.g. to be sourced by a shell. The values are quoted and
+// escaped as the format requires.
+func FormatEnvVars(vars []EnvVar, comments []string, format string) ([]byte, error) {
+	if format == "" {
+		format = GetDefaultEnvFormat()
+	}
+	commentWord := "#"
+	if format == EnvFormatCmd {
+		commentWord = "REM"
+	}
+	var sb strings.Builder
+	for _, comment := range comments {
+		sb.WriteString(commentWord + " " + strings.ReplaceAll(comment, "\n", " ") + "\n")
+	}
+	for _, v := range vars {
+		if !isValidEnvVarName(v.Name) {
+			return nil, fmt.Errorf("invalid environment variable name: %q", v.Name)
+		}
+		if strings.ContainsAny(v.Value, "\r\n\x00") {
+			return nil, fmt.Errorf("the value of %s environment variable contains line break", v.Name)
+		}
+		switch format {
+		case EnvFormatBash, EnvFormatZsh:
+			sb.WriteString("export " + v.Name + "=" + quotePosixShell(v.Value) + "\n")
+		case EnvFormatFish:
+			sb.WriteString("set -gx " + v.Name + " " + quoteFishShell(v.Value) + "\n")
+		case EnvFormatPowerShell:
+			sb.WriteString("$Env:" + v.Name + " = '" + strings.ReplaceAll(v.Value, "'", "''") + "'\n")
+		case EnvFormatCmd:
+			if strings.Contains(v.Value, `"`) {
+				return nil, fmt.Errorf("the value of %s environment variable contains double quote, unsupported by %s format", v.Name, format)
+			}
+			sb.WriteString(`set "` + v.Name + "=" + strings.ReplaceAll(v.Value, "%", "%%") + "\"\n")
+		case EnvFormatDotenv:
+			sb.WriteString(v.Name + "=" + quoteDotenv(v.Value) + "\n")
+		case EnvFormatDocker:
+			sb.WriteString(v.Name + "=" + v.Value + "\n")
+		default:
+			return nil, fmt.Errorf("unsupported environment variables format: %s, supported formats: %s",
+				format, strings.Join(GetEnvFormats(), ", "))
+		}
+	}
+	return []byte(sb.String()), nil
+}
+
+func isValidEnvVarName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// isShellSafe checks whether a value needs no quoting in shells, e.g.
+// base64-encoded session token.
+func isShellSafe(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.ContainsRune("_-.,:/+=@", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// quotePosixShell quotes a value with single quotes. A single quote in the
+// value closes the quotes, is escaped, and opens the quotes again.
+func quotePosixShell(s string) string {
+	if isShellSafe(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFishShell quotes a value with single quotes. Fish recognizes `\'`
+// and `\\` escapes in single quotes.
+func quoteFishShell(s string) string {
+	if isShellSafe(s) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// quoteDotenv quotes a value with single quotes, which dotenv parsers take
+// literally, or with double quotes when the value contains a single quote.
+func quoteDotenv(s string) string {
+	if isShellSafe(s) {
+		return s
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`).Replace(s) + `"`
+}
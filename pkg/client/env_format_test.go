@@ -0,0 +1,93 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestFormatEnvVars(t *testing.T) {
+	testFailed := 0
+	vars := []EnvVar{
+		{"AWS_ACCESS_KEY_ID", "ASBGQSJR7ZAFSXODTUMO"},
+		{"AWS_SESSION_TOKEN", `it's "$HOME" 100%\`},
+	}
+	comments := []string{"Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith"}
+	for i, test := range []struct {
+		format     string
+		exp        string
+		shouldFail bool
+	}{
+		{
+			format: EnvFormatBash,
+			exp: "# Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith\n" +
+				"export AWS_ACCESS_KEY_ID=ASBGQSJR7ZAFSXODTUMO\n" +
+				`export AWS_SESSION_TOKEN='it'\''s "$HOME" 100%\'` + "\n",
+		},
+		{
+			format: EnvFormatFish,
+			exp: "# Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith\n" +
+				"set -gx AWS_ACCESS_KEY_ID ASBGQSJR7ZAFSXODTUMO\n" +
+				`set -gx AWS_SESSION_TOKEN 'it\'s "$HOME" 100%\\'` + "\n",
+		},
+		{
+			format: EnvFormatPowerShell,
+			exp: "# Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith\n" +
+				"$Env:AWS_ACCESS_KEY_ID = 'ASBGQSJR7ZAFSXODTUMO'\n" +
+				`$Env:AWS_SESSION_TOKEN = 'it''s "$HOME" 100%\'` + "\n",
+		},
+		{
+			// The value with double quotes can not be set in cmd.
+			format:     EnvFormatCmd,
+			shouldFail: true,
+		},
+		{
+			format: EnvFormatDotenv,
+			exp: "# Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith\n" +
+				"AWS_ACCESS_KEY_ID=ASBGQSJR7ZAFSXODTUMO\n" +
+				`AWS_SESSION_TOKEN="it's \"\$HOME\" 100%\\"` + "\n",
+		},
+		{
+			format: EnvFormatDocker,
+			exp: "# Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith\n" +
+				"AWS_ACCESS_KEY_ID=ASBGQSJR7ZAFSXODTUMO\n" +
+				`AWS_SESSION_TOKEN=it's "$HOME" 100%\` + "\n",
+		},
+		{
+			format:     "tcsh",
+			shouldFail: true,
+		},
+	} {
+		b, err := FormatEnvVars(vars, comments, test.format)
+		if err != nil {
+			if !test.shouldFail {
+				t.Logf("FAIL: Test %d: format '%s', expected to pass, but threw error: %v", i, test.format, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: format '%s', expected to fail, failed: %v", i, test.format, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: format '%s', expected to fail, but passed", i, test.format)
+			testFailed++
+			continue
+		}
+		if string(b) != test.exp {
+			t.Logf("FAIL: Test %d: format '%s', mismatch\n%s\n(expected) vs\n%s", i, test.format, test.exp, b)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: format '%s', expected to pass, passed", i, test.format)
+	}
+
+	// The percent signs are doubled in batch files.
+	b, err := FormatEnvVars([]EnvVar{{"AWS_SESSION_TOKEN", "100%&more"}}, comments, EnvFormatCmd)
+	exp := "REM Assumed Role ARN: arn:aws:sts::000000000001:assumed-role/Administrator/jsmith\n" +
+		`set "AWS_SESSION_TOKEN=100%%&more"` + "\n"
+	if err != nil || string(b) != exp {
+		t.Logf("FAIL: format 'cmd', mismatch\n%s\n(expected) vs\n%s (%v)", exp, b, err)
+		testFailed++
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
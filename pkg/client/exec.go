@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// execConflictingEnvVars are the environment variables removed from the
+// environment of a command, because they would make AWS SDKs and CLI
+// ignore the credentials passed to the command, or mix them with other
+// credentials.
+var execConflictingEnvVars = []string{
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_SECURITY_TOKEN",
+}
+
+// GetExecEnv returns the environment of a command run with the credentials.
+// The variables of the base environment that conflict with the credentials
+// are removed.
+func (c *AwsCredentials) GetExecEnv(base []string) []string {
+	vars := c.GetEnvVars()
+	drop := map[string]bool{}
+	for _, k := range execConflictingEnvVars {
+		drop[k] = true
+	}
+	for _, v := range vars {
+		drop[v.Name] = true
+	}
+	env := []string{}
+	for _, kv := range base {
+		k := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			k = kv[:i]
+		}
+		if drop[k] {
+			continue
+		}
+		env = append(env, kv)
+	}
+	for _, v := range vars {
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return env
+}
+
+// ExecWithAwsCredentials runs a command with the credentials in its
+// environment. The signals received by the tool are forwarded to the
+// command. It returns the exit code of the command.
+func ExecWithAwsCredentials(creds *AwsCredentials, args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, fmt.Errorf("no command to execute")
+	}
+	if err := creds.IsValid(); err != nil {
+		return 1, err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = creds.GetExecEnv(os.Environ())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 1, fmt.Errorf("Erred executing %s: %s", args[0], err)
+	}
+	log.Debugf("Started %s (pid %d) with credentials of %s", args[0], cmd.Process.Pid, creds.ProfileName)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				log.Debugf("Forwarding %s to pid %d", sig, cmd.Process.Pid)
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 1, fmt.Errorf("Erred waiting for %s: %s", args[0], err)
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		// Follow the shell convention for the commands killed by a signal.
+		return 128 + int(status.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"io/ioutil"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGetExecEnv(t *testing.T) {
+	fp := path.Join("../../assets/tests", "aws.sts.response.1.json")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	resp, err := NewAwsStsResponseFromBytes(content)
+	if err != nil {
+		t.Fatalf("failed parsing '%s': %v", fp, err)
+	}
+	creds, err := NewAwsCredentialsFromStsResponse(resp)
+	if err != nil {
+		t.Fatalf("failed creating credentials: %v", err)
+	}
+	creds.DefaultRegion = "us-west-2"
+	env := creds.GetExecEnv([]string{
+		"HOME=/home/jsmith",
+		"AWS_PROFILE=prod",
+		"AWS_DEFAULT_PROFILE=prod",
+		"AWS_ACCESS_KEY_ID=OLD",
+		"AWS_REGION=eu-west-1",
+		"AWS_CA_BUNDLE=/etc/ssl/ca.pem",
+	})
+	vars := map[string]string{}
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		if _, exists := vars[kv[:i]]; exists {
+			t.Fatalf("FAIL: duplicate variable %s in %v", kv[:i], env)
+		}
+		vars[kv[:i]] = kv[i+1:]
+	}
+	testFailed := 0
+	for i, test := range []struct {
+		name   string
+		value  string
+		exists bool
+	}{
+		{name: "HOME", value: "/home/jsmith", exists: true},
+		{name: "AWS_CA_BUNDLE", value: "/etc/ssl/ca.pem", exists: true},
+		{name: "AWS_PROFILE"},
+		{name: "AWS_DEFAULT_PROFILE"},
+		{name: "AWS_ACCESS_KEY_ID", value: creds.AccessKeyId, exists: true},
+		{name: "AWS_SESSION_TOKEN", value: creds.SessionToken, exists: true},
+		{name: "AWS_REGION", value: "us-west-2", exists: true},
+		{name: "AWS_DEFAULT_REGION", value: "us-west-2", exists: true},
+		{name: "AWS_CREDENTIAL_EXPIRATION", value: "2019-07-17T12:18:00Z", exists: true},
+	} {
+		v, exists := vars[test.name]
+		if exists != test.exists || v != test.value {
+			t.Logf("FAIL: Test %d: %s, expected '%s' (%t), but got '%s' (%t)", i, test.name, test.value, test.exists, v, exists)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: %s, expected to pass, passed", i, test.name)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestExecWithAwsCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX shell")
+	}
+	fp := path.Join("../../assets/tests", "aws.sts.response.1.json")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	resp, err := NewAwsStsResponseFromBytes(content)
+	if err != nil {
+		t.Fatalf("failed parsing '%s': %v", fp, err)
+	}
+	creds, err := NewAwsCredentialsFromStsResponse(resp)
+	if err != nil {
+		t.Fatalf("failed creating credentials: %v", err)
+	}
+	testFailed := 0
+	for i, test := range []struct {
+		script string
+		code   int
+	}{
+		{script: `test "$AWS_ACCESS_KEY_ID" = "` + creds.AccessKeyId + `" && test -z "$AWS_PROFILE"`, code: 0},
+		{script: "exit 3", code: 3},
+		{script: "kill -TERM $$", code: 128 + 15},
+	} {
+		code, err := ExecWithAwsCredentials(creds, []string{"sh", "-c", test.script})
+		if err != nil {
+			t.Logf("FAIL: Test %d: script '%s', expected to pass, but threw error: %v", i, test.script, err)
+			testFailed++
+			continue
+		}
+		if code != test.code {
+			t.Logf("FAIL: Test %d: script '%s', expected exit code %d, but got %d", i, test.script, test.code, code)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: script '%s', expected to pass, passed", i, test.script)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
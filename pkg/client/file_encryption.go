@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var encryptedFileMagic = []byte("GGK1")
+
+const (
+	encryptedFileSaltSize = 16
+	encryptionKeySize     = 32
+)
+
+// fileEncryptor encrypts the files stored by the tool, e.g. cached AWS
+// credentials, with AES-256-GCM. The key is derived either from a
+// passphrase with scrypt, or from the content of a local key file.
+type fileEncryptor struct {
+	Passphrase string
+	KeyFile    string
+}
+
+// deriveKey returns the encryption key for a salt. The key file is
+// created with random content when it does not exist.
+func (e *fileEncryptor) deriveKey(salt []byte) ([]byte, error) {
+	if e.Passphrase != "" {
+		return scrypt.Key([]byte(e.Passphrase), salt, 1<<15, 8, 1, encryptionKeySize)
+	}
+	if e.KeyFile == "" {
+		return nil, fmt.Errorf("neither passphrase nor key file is set")
+	}
+	fp := ExpandFilePath(e.KeyFile)
+	secret, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		secret = make([]byte, encryptionKeySize)
+		if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(fp, secret, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(secret) < encryptionKeySize {
+		return nil, fmt.Errorf("key file %s is shorter than %d bytes", fp, encryptionKeySize)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(salt)
+	return mac.Sum(nil), nil
+}
+
+// Seal encrypts the data. The additional data is authenticated, but not
+// stored, e.g. the identifier of the data.
+func (e *fileEncryptor) Seal(data, additionalData []byte) ([]byte, error) {
+	salt := make([]byte, encryptedFileSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := e.getAEAD(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.Write(encryptedFileMagic)
+	b.Write(salt)
+	b.Write(nonce)
+	b.Write(aead.Seal(nil, nonce, data, additionalData))
+	return b.Bytes(), nil
+}
+
+// Open decrypts the data encrypted by Seal.
+func (e *fileEncryptor) Open(data, additionalData []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedFileMagic) {
+		return nil, fmt.Errorf("unsupported encrypted file format")
+	}
+	data = data[len(encryptedFileMagic):]
+	if len(data) < encryptedFileSaltSize {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	salt, data := data[:encryptedFileSaltSize], data[encryptedFileSaltSize:]
+	aead, err := e.getAEAD(salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	nonce, data := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, data, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt, the key is likely different: %s", err)
+	}
+	return plain, nil
+}
+
+func (e *fileEncryptor) getAEAD(salt []byte) (cipher.AEAD, error) {
+	key, err := e.deriveKey(salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %s", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+
+package client
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileLock is an advisory lock held on a file.
+type fileLock struct {
+	fp string
+	fh *os.File
+}
+
+// lockFile takes an exclusive advisory lock on a lock file. It blocks
+// until the lock is available. The lock file is removed on unlock.
+func lockFile(fp string) (*fileLock, error) {
+	for {
+		fh, err := os.OpenFile(fp, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			err = unix.Flock(int(fh.Fd()), unix.LOCK_EX)
+			if err != unix.EINTR {
+				break
+			}
+		}
+		if err != nil {
+			fh.Close()
+			return nil, err
+		}
+		// The previous holder may have removed the file while this
+		// process was waiting for the lock. Then, the lock is retaken on
+		// the file currently at the path.
+		if isSameFile(fp, fh) {
+			return &fileLock{fp: fp, fh: fh}, nil
+		}
+		fh.Close()
+	}
+}
+
+// Unlock removes the lock file and releases the lock.
+func (l *fileLock) Unlock() error {
+	defer l.fh.Close()
+	os.Remove(l.fp)
+	return unix.Flock(int(l.fh.Fd()), unix.LOCK_UN)
+}
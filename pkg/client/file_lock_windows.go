@@ -0,0 +1,47 @@
+//go:build windows
+
+package client
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileLock is an advisory lock held on a file.
+type fileLock struct {
+	fp string
+	fh *os.File
+}
+
+// lockFile takes an exclusive lock on a lock file. It blocks until the
+// lock is available. The lock file is removed on unlock.
+func lockFile(fp string) (*fileLock, error) {
+	for {
+		fh, err := os.OpenFile(fp, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		ol := new(windows.Overlapped)
+		if err := windows.LockFileEx(windows.Handle(fh.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol); err != nil {
+			fh.Close()
+			return nil, err
+		}
+		// The previous holder may have removed the file while this
+		// process was waiting for the lock.
+		if isSameFile(fp, fh) {
+			return &fileLock{fp: fp, fh: fh}, nil
+		}
+		fh.Close()
+	}
+}
+
+// Unlock releases the lock and removes the lock file. The removal fails
+// while another process has the file open, and the file is left to it.
+func (l *fileLock) Unlock() error {
+	ol := new(windows.Overlapped)
+	err := windows.UnlockFileEx(windows.Handle(l.fh.Fd()), 0, 1, 0, ol)
+	l.fh.Close()
+	os.Remove(l.fp)
+	return err
+}
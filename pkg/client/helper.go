@@ -1,7 +1,11 @@
 package client
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
 )
 
@@ -15,3 +19,45 @@ func ExpandFilePath(s string) string {
 	}
 	return s
 }
+
+// writeFileAtomic writes data to a temporary file in the directory of the
+// file and renames it to the file.
+func writeFileAtomic(fp string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(fp)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(fp)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Erred creating temporary file for %s: %s", fp, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Erred writing temporary file for %s: %s", fp, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Erred syncing temporary file for %s: %s", fp, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Erred closing temporary file for %s: %s", fp, err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("Erred changing permissions of temporary file for %s: %s", fp, err)
+	}
+	if err := os.Rename(tmp.Name(), fp); err != nil {
+		return fmt.Errorf("Erred replacing %s: %s", fp, err)
+	}
+	return nil
+}
+
+// isSameFile checks whether the path refers to the open file.
+func isSameFile(fp string, fh *os.File) bool {
+	fi, err := os.Stat(fp)
+	if err != nil {
+		return false
+	}
+	fhi, err := fh.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, fhi)
+}
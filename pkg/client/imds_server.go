@@ -0,0 +1,244 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultImdsHopLimit is the default hop limit of the responses of
+	// EC2 instance metadata service. The containers on a bridge network
+	// need the hop limit of 2.
+	DefaultImdsHopLimit = 1
+	// MaxImdsTokenTTL is the maximum lifetime of IMDSv2 session token.
+	MaxImdsTokenTTL = 6 * time.Hour
+
+	imdsTokenPath       = "/latest/api/token"
+	imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	imdsRegionPath      = "/latest/meta-data/placement/region"
+	imdsTokenHeader     = "X-aws-ec2-metadata-token"
+	imdsTokenTTLHeader  = "X-aws-ec2-metadata-token-ttl-seconds"
+)
+
+// ImdsCredentialsResponse is the structure holding the credentials of an
+// instance profile role returned by EC2 instance metadata service.
+type ImdsCredentialsResponse struct {
+	Code            string `json:"Code"`
+	LastUpdated     string `json:"LastUpdated"`
+	Type            string `json:"Type"`
+	AccessKeyId     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// ImdsServer emulates EC2 instance metadata service, IMDSv2, for AWS SDKs
+// and CLI using the instance profile credentials provider. Only session
+// token requests and the instance profile credentials are implemented.
+type ImdsServer struct {
+	Credentials *RefreshingAwsCredentials
+	// RoleName is the name of the role returned as the instance profile
+	// role.
+	RoleName string
+	// HopLimit is the IP TTL of the responses. With the hop limit of 1,
+	// the responses do not reach the containers behind NAT.
+	HopLimit int
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+}
+
+// NewImdsServer returns ImdsServer instance.
+func NewImdsServer(creds *RefreshingAwsCredentials, roleName string) *ImdsServer {
+	return &ImdsServer{
+		Credentials: creds,
+		RoleName:    roleName,
+		HopLimit:    DefaultImdsHopLimit,
+		tokens:      make(map[string]time.Time),
+	}
+}
+
+// Listen listens on a loopback or link-local address, e.g. `127.0.0.1:0`,
+// or `169.254.169.254:80` assigned to the loopback interface. The responses
+// are sent with the hop limit of the server.
+func (s *ImdsServer) Listen(addr string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %s: %s", addr, err)
+	}
+	ip := net.ParseIP(host)
+	if host != "localhost" && (ip == nil || !(ip.IsLoopback() || ip.IsLinkLocalUnicast())) {
+		return nil, fmt.Errorf("listen address %s is neither loopback nor link-local address", addr)
+	}
+	if s.HopLimit < 1 || s.HopLimit > 64 {
+		return nil, fmt.Errorf("hop limit %d is not between 1 and 64", s.HopLimit)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &hopLimitListener{Listener: listener, hopLimit: s.HopLimit}, nil
+}
+
+// GetEnvVars returns the environment variables pointing AWS SDKs and CLI
+// to the server listening on the address.
+func (s *ImdsServer) GetEnvVars(addr net.Addr) []EnvVar {
+	return []EnvVar{
+		{"AWS_EC2_METADATA_SERVICE_ENDPOINT", "http://" + addr.String() + "/"},
+	}
+}
+
+// ServeHTTP responds to the requests for session tokens and metadata.
+func (s *ImdsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == imdsTokenPath {
+		s.serveToken(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ttl, ok := s.getTokenTTL(r.Header.Get(imdsTokenHeader))
+	if !ok {
+		// IMDSv1 requests, i.e. without session token, are not supported.
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set(imdsTokenTTLHeader, strconv.Itoa(int(ttl.Seconds())))
+	switch r.URL.Path {
+	case strings.TrimSuffix(imdsCredentialsPath, "/"), imdsCredentialsPath:
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, s.RoleName)
+	case imdsCredentialsPath + s.RoleName:
+		s.serveCredentials(w, r)
+	case imdsRegionPath:
+		creds, err := s.Credentials.Get()
+		if err != nil || creds.DefaultRegion == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, creds.DefaultRegion)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveToken issues IMDSv2 session token.
+func (s *ImdsServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Allow", http.MethodPut)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The token requests forwarded by a proxy are rejected, as EC2 does.
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	seconds, err := strconv.Atoi(r.Header.Get(imdsTokenTTLHeader))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxImdsTokenTTL {
+		http.Error(w, "invalid "+imdsTokenTTLHeader+" header", http.StatusBadRequest)
+		return
+	}
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	s.mu.Lock()
+	for t, expiration := range s.tokens {
+		if !now.Before(expiration) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(time.Duration(seconds) * time.Second)
+	s.mu.Unlock()
+	log.Debugf("Issued IMDSv2 session token to %s for %ds", r.RemoteAddr, seconds)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set(imdsTokenTTLHeader, strconv.Itoa(seconds))
+	io.WriteString(w, token)
+}
+
+// getTokenTTL returns the remaining lifetime of a session token. It returns
+// false when the token was not issued by the server or expired.
+func (s *ImdsServer) getTokenTTL(token string) (time.Duration, bool) {
+	if token == "" {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiration, exists := s.tokens[token]
+	if !exists {
+		return 0, false
+	}
+	ttl := time.Until(expiration)
+	if ttl <= 0 {
+		delete(s.tokens, token)
+		return 0, false
+	}
+	return ttl.Round(time.Second), true
+}
+
+func (s *ImdsServer) serveCredentials(w http.ResponseWriter, r *http.Request) {
+	creds, err := s.Credentials.Get()
+	if err != nil {
+		log.Errorf("Failed to obtain AWS credentials for %s: %s", r.RemoteAddr, err)
+		http.Error(w, "failed to obtain credentials", http.StatusServiceUnavailable)
+		return
+	}
+	resp := &ImdsCredentialsResponse{
+		Code:            "Success",
+		LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+		Type:            "AWS-HMAC",
+		AccessKeyId:     creds.AccessKeyId,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+	}
+	if !creds.Expiration.IsZero() {
+		resp.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debugf("Failed to write credentials response to %s: %s", r.RemoteAddr, err)
+		return
+	}
+	log.Debugf("Served AWS credentials to %s", r.RemoteAddr)
+}
+
+// hopLimitListener sets the hop limit, i.e. IP TTL, of the accepted
+// connections.
+type hopLimitListener struct {
+	net.Listener
+	hopLimit int
+}
+
+func (l *hopLimitListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+		err = ipv6.NewConn(conn).SetHopLimit(l.hopLimit)
+	} else {
+		err = ipv4.NewConn(conn).SetTTL(l.hopLimit)
+	}
+	if err != nil {
+		log.Debugf("Failed to set hop limit of connection from %s: %s", conn.RemoteAddr(), err)
+	}
+	return conn, nil
+}
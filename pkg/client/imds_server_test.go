@@ -0,0 +1,140 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestImdsServer(t *testing.T) {
+	creds := NewRefreshingAwsCredentials(nil)
+	creds.Set(&AwsCredentials{
+		AccessKeyId:     "KEY",
+		SecretAccessKey: "SECRET",
+		SessionToken:    "TOKEN",
+		DefaultRegion:   "us-west-2",
+		Expiration:      time.Now().Add(time.Hour),
+	})
+	server := NewImdsServer(creds, "Administrator")
+	server.HopLimit = 2
+	listener, err := server.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	go http.Serve(listener, server)
+	defer listener.Close()
+	endpoint := "http://" + listener.Addr().String()
+
+	token := ""
+	testFailed := 0
+	for i, test := range []struct {
+		method  string
+		path    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{method: "GET", path: imdsCredentialsPath, code: http.StatusUnauthorized},
+		{method: "PUT", path: imdsTokenPath, code: http.StatusBadRequest},
+		{method: "PUT", path: imdsTokenPath, headers: map[string]string{imdsTokenTTLHeader: "21601"}, code: http.StatusBadRequest},
+		{method: "PUT", path: imdsTokenPath, headers: map[string]string{imdsTokenTTLHeader: "60", "X-Forwarded-For": "10.0.0.1"}, code: http.StatusForbidden},
+		{method: "GET", path: imdsTokenPath, headers: map[string]string{imdsTokenTTLHeader: "60"}, code: http.StatusMethodNotAllowed},
+		{method: "PUT", path: imdsTokenPath, headers: map[string]string{imdsTokenTTLHeader: "21600"}, code: http.StatusOK},
+		{method: "GET", path: imdsCredentialsPath, headers: map[string]string{imdsTokenHeader: "invalid"}, code: http.StatusUnauthorized},
+		{method: "GET", path: imdsCredentialsPath, code: http.StatusOK, body: "Administrator"},
+		{method: "GET", path: imdsCredentialsPath + "Administrator", code: http.StatusOK},
+		{method: "GET", path: imdsCredentialsPath + "Developer", code: http.StatusNotFound},
+		{method: "GET", path: imdsRegionPath, code: http.StatusOK, body: "us-west-2"},
+	} {
+		req, err := http.NewRequest(test.method, endpoint+test.path, nil)
+		if err != nil {
+			t.Fatalf("failed creating request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set(imdsTokenHeader, token)
+		}
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed requesting %s: %v", test.path, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Logf("FAIL: Test %d: %s %s, expected status %d, but got %d", i, test.method, test.path, test.code, resp.StatusCode)
+			testFailed++
+			continue
+		}
+		if test.code == http.StatusOK && resp.Header.Get(imdsTokenTTLHeader) == "" {
+			t.Logf("FAIL: Test %d: %s %s, no %s header", i, test.method, test.path, imdsTokenTTLHeader)
+			testFailed++
+			continue
+		}
+		if test.path == imdsTokenPath && test.code == http.StatusOK {
+			token = string(body)
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Logf("FAIL: Test %d: %s %s, expected '%s', but got '%s'", i, test.method, test.path, test.body, body)
+			testFailed++
+			continue
+		}
+		if test.path == imdsCredentialsPath+"Administrator" {
+			data := &ImdsCredentialsResponse{}
+			if err := json.Unmarshal(body, data); err != nil {
+				t.Logf("FAIL: Test %d: response is not JSON: %v", i, err)
+				testFailed++
+				continue
+			}
+			if data.Code != "Success" || data.AccessKeyId != "KEY" || data.Token != "TOKEN" || data.Expiration == "" {
+				t.Logf("FAIL: Test %d: unexpected response: %s", i, body)
+				testFailed++
+				continue
+			}
+		}
+		t.Logf("PASS: Test %d: %s %s, expected to pass, passed", i, test.method, test.path)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestImdsServerListen(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		addr       string
+		hopLimit   int
+		shouldFail bool
+	}{
+		{addr: "127.0.0.1:0", hopLimit: 1},
+		{addr: "127.0.0.1:0", hopLimit: 0, shouldFail: true},
+		{addr: "0.0.0.0:0", hopLimit: 1, shouldFail: true},
+		{addr: "192.0.2.1:0", hopLimit: 1, shouldFail: true},
+	} {
+		server := NewImdsServer(nil, "Administrator")
+		server.HopLimit = test.hopLimit
+		listener, err := server.Listen(test.addr)
+		if err != nil {
+			if !test.shouldFail {
+				t.Logf("FAIL: Test %d: address '%s', expected to pass, but threw error: %v", i, test.addr, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: address '%s', expected to fail, failed: %v", i, test.addr, err)
+			continue
+		}
+		listener.Close()
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: address '%s', expected to fail, but passed", i, test.addr)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: address '%s', expected to pass, passed", i, test.addr)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IniFile is the structure holding the content of INI file, e.g.
+// `.aws/credentials`. It keeps every line of the file as is, including
+// comments and blank lines, so that the file could be written back
+// without altering the parts that were not changed.
+type IniFile struct {
+	// Head is the lines preceding the first section.
+	Head     []string
+	Sections []*IniSection
+}
+
+// IniSection is the structure holding a section of INI file.
+type IniSection struct {
+	Name string
+	// Header is the raw line with the name of the section, e.g. `[default]`.
+	Header string
+	Lines  []string
+}
+
+// NewIniFileFromString returns IniFile instance from an input string.
+func NewIniFileFromString(s string) *IniFile {
+	return NewIniFileFromBytes([]byte(s))
+}
+
+// NewIniFileFromBytes returns IniFile instance from an input byte array.
+func NewIniFileFromBytes(s []byte) *IniFile {
+	f := &IniFile{}
+	content := strings.ReplaceAll(string(s), "\r\n", "\n")
+	if content == "" {
+		return f
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	var section *IniSection
+	for _, line := range lines {
+		if name, ok := parseIniSectionHeader(line); ok {
+			section = &IniSection{Name: name, Header: line}
+			f.Sections = append(f.Sections, section)
+			continue
+		}
+		if section == nil {
+			f.Head = append(f.Head, line)
+			continue
+		}
+		section.Lines = append(section.Lines, line)
+	}
+	return f
+}
+
+// parseIniSectionHeader returns the name of a section when the line is a
+// section header, e.g. `[profile prod]`.
+func parseIniSectionHeader(line string) (string, bool) {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "[") {
+		return "", false
+	}
+	i := strings.Index(s, "]")
+	if i < 0 {
+		return "", false
+	}
+	if rest := strings.TrimSpace(s[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") && !strings.HasPrefix(rest, ";") {
+		return "", false
+	}
+	return strings.TrimSpace(s[1:i]), true
+}
+
+// parseIniKey returns the key of a line when the line is a key-value pair.
+func parseIniKey(line string) (string, bool) {
+	s := strings.TrimSpace(line)
+	if s == "" || strings.HasPrefix(s, "#") || strings.HasPrefix(s, ";") {
+		return "", false
+	}
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return "", false
+	}
+	return strings.TrimSpace(s[:i]), true
+}
+
+// GetSection returns the section with the exact name, or nil.
+func (f *IniFile) GetSection(name string) *IniSection {
+	for _, section := range f.Sections {
+		if section.Name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+// AddSection returns the section with the exact name. The section is
+// appended to the file when it does not exist.
+func (f *IniFile) AddSection(name string) *IniSection {
+	if section := f.GetSection(name); section != nil {
+		return section
+	}
+	if n := len(f.Sections); n > 0 {
+		// Separate the new section from the previous one.
+		prev := f.Sections[n-1]
+		if len(prev.Lines) == 0 || strings.TrimSpace(prev.Lines[len(prev.Lines)-1]) != "" {
+			prev.Lines = append(prev.Lines, "")
+		}
+	} else if len(f.Head) > 0 && strings.TrimSpace(f.Head[len(f.Head)-1]) != "" {
+		f.Head = append(f.Head, "")
+	}
+	section := &IniSection{Name: name, Header: "[" + name + "]"}
+	f.Sections = append(f.Sections, section)
+	return section
+}
+
+// Bytes returns the content of the file.
+func (f *IniFile) Bytes() []byte {
+	var sb strings.Builder
+	for _, line := range f.Head {
+		sb.WriteString(line)
+		sb.WriteRune('\n')
+	}
+	for _, section := range f.Sections {
+		sb.WriteString(section.Header)
+		sb.WriteRune('\n')
+		for _, line := range section.Lines {
+			sb.WriteString(line)
+			sb.WriteRune('\n')
+		}
+	}
+	return []byte(sb.String())
+}
+
+// Get returns the value of a key in the section.
+func (s *IniSection) Get(key string) (string, bool) {
+	for _, line := range s.Lines {
+		if k, ok := parseIniKey(line); ok && k == key {
+			v := strings.TrimSpace(line)
+			return strings.TrimSpace(v[strings.Index(v, "=")+1:]), true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of a key in the section. An existing key is updated
+// in place. A new key is added after the last key of the section.
+func (s *IniSection) Set(key, value string) {
+	line := key + "=" + value
+	last := -1
+	for i, l := range s.Lines {
+		k, ok := parseIniKey(l)
+		if !ok {
+			continue
+		}
+		if k == key {
+			s.Lines[i] = line
+			return
+		}
+		last = i
+	}
+	s.insert(last+1, line)
+}
+
+// Delete removes a key from the section.
+func (s *IniSection) Delete(key string) {
+	lines := s.Lines[:0]
+	for _, l := range s.Lines {
+		if k, ok := parseIniKey(l); ok && k == key {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	s.Lines = lines
+}
+
+// SetComment sets a comment line in the section, e.g. `# Assumed Role
+// ARN: <arn>`. An existing comment with the same label is updated in
+// place. A new comment is added before the first key of the section.
+func (s *IniSection) SetComment(label, value string) {
+	prefix := "# " + label + ":"
+	line := prefix + " " + value
+	first := len(s.Lines)
+	for i, l := range s.Lines {
+		if strings.HasPrefix(strings.TrimSpace(l), prefix) {
+			s.Lines[i] = line
+			return
+		}
+		if _, ok := parseIniKey(l); ok && i < first {
+			first = i
+		}
+	}
+	if first == len(s.Lines) {
+		// The section has no keys, keep the trailing blank lines last.
+		for first > 0 && strings.TrimSpace(s.Lines[first-1]) == "" {
+			first--
+		}
+	}
+	s.insert(first, line)
+}
+
+func (s *IniSection) insert(i int, line string) {
+	s.Lines = append(s.Lines, "")
+	copy(s.Lines[i+1:], s.Lines[i:])
+	s.Lines[i] = line
+}
+
+// ReadIniFile reads INI file. A file that does not exist is empty.
+func ReadIniFile(fp string) (*IniFile, error) {
+	b, err := ioutil.ReadFile(fp)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &IniFile{}, nil
+		}
+		return nil, err
+	}
+	return NewIniFileFromBytes(b), nil
+}
+
+// UpdateIniFile reads INI file, applies the changes made by the update
+// function, and writes the file back. The file is locked for the duration
+// of the update, so that concurrent updates do not overwrite each other.
+// The file is replaced atomically and has 0600 permissions.
+func UpdateIniFile(fp string, update func(*IniFile) error) error {
+	// Update the target of a symbolic link rather than replacing the link.
+	if realPath, err := filepath.EvalSymlinks(fp); err == nil {
+		fp = realPath
+	}
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return fmt.Errorf("Erred creating directory for %s: %s", fp, err)
+	}
+	lock, err := lockFile(fp + ".lock")
+	if err != nil {
+		return fmt.Errorf("Erred locking %s: %s", fp, err)
+	}
+	defer lock.Unlock()
+
+	f, err := ReadIniFile(fp)
+	if err != nil {
+		return fmt.Errorf("Erred reading existing file %s: %s", fp, err)
+	}
+	if err := update(f); err != nil {
+		return err
+	}
+	return writeFileAtomic(fp, f.Bytes(), 0600)
+}
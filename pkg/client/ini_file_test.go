@@ -0,0 +1,122 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+func TestIniFileUpdate(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		input   string
+		profile string
+		keys    map[string]string
+		exp     string
+	}{
+		{
+			input:   "",
+			profile: "default",
+			keys:    map[string]string{"region": "us-east-1"},
+			exp:     "[default]\nregion=us-east-1\n",
+		},
+		{
+			// The comment mentioning the profile must not be mistaken
+			// for the section.
+			input: "# managed by go-get-aws-keys, see [prod] below\n" +
+				"[dev]\n" +
+				"; the keys of [prod] profile are rotated hourly\n" +
+				"aws_access_key_id=DEV\n" +
+				"\n" +
+				"[prod]\n" +
+				"aws_access_key_id = OLD\n" +
+				"# keep this comment\n" +
+				"custom_key=custom\n" +
+				"\n" +
+				"[production]\n" +
+				"aws_access_key_id=PRODUCTION\n",
+			profile: "prod",
+			keys:    map[string]string{"aws_access_key_id": "NEW", "aws_session_token": "TOKEN"},
+			exp: "# managed by go-get-aws-keys, see [prod] below\n" +
+				"[dev]\n" +
+				"; the keys of [prod] profile are rotated hourly\n" +
+				"aws_access_key_id=DEV\n" +
+				"\n" +
+				"[prod]\n" +
+				"aws_access_key_id=NEW\n" +
+				"# keep this comment\n" +
+				"custom_key=custom\n" +
+				"aws_session_token=TOKEN\n" +
+				"\n" +
+				"[production]\n" +
+				"aws_access_key_id=PRODUCTION\n",
+		},
+		{
+			input:   "[dev]\naws_access_key_id=DEV\n",
+			profile: "prod",
+			keys:    map[string]string{"aws_access_key_id": "NEW"},
+			exp:     "[dev]\naws_access_key_id=DEV\n\n[prod]\naws_access_key_id=NEW\n",
+		},
+	} {
+		f := NewIniFileFromString(test.input)
+		section := f.AddSection(test.profile)
+		for _, k := range []string{"region", "aws_access_key_id", "aws_session_token"} {
+			if v, exists := test.keys[k]; exists {
+				section.Set(k, v)
+			}
+		}
+		if s := string(f.Bytes()); s != test.exp {
+			t.Logf("FAIL: Test %d: mismatch\n%s\n(expected) vs\n%s", i, test.exp, s)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: profile '%s', expected to pass, passed", i, test.profile)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestIniFileConcurrentUpdate(t *testing.T) {
+	tmpDir := t.TempDir()
+	fp := path.Join(tmpDir, "credentials")
+	var wg sync.WaitGroup
+	errors := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errors <- UpdateIniFile(fp, func(f *IniFile) error {
+				f.AddSection(fmt.Sprintf("profile-%d", i)).Set("aws_access_key_id", fmt.Sprintf("KEY%d", i))
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		if err != nil {
+			t.Fatalf("failed updating %s: %v", fp, err)
+		}
+	}
+	f, err := ReadIniFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading %s: %v", fp, err)
+	}
+	if len(f.Sections) != 20 {
+		t.Fatalf("FAIL: expected 20 profiles, but found %d:\n%s", len(f.Sections), f.Bytes())
+	}
+	fi, err := os.Stat(fp)
+	if err != nil {
+		t.Fatalf("failed checking %s: %v", fp, err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("FAIL: expected 0600 permissions, but found %o", fi.Mode().Perm())
+	}
+	if _, err := os.Stat(fp + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("FAIL: expected the lock file to be removed, but found it: %v", err)
+	}
+	t.Logf("PASS: 20 concurrent updates preserved all profiles")
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"time"
+)
+
+const (
+	// ResultFormatJSON is JSON format of LoginResult.
+	ResultFormatJSON = "json"
+	// ResultFormatYAML is YAML format of LoginResult.
+	ResultFormatYAML = "yaml"
+)
+
+const (
+	// AwsRoleStatusAssumed is the status of the role assumed with SAML
+	// assertions.
+	AwsRoleStatusAssumed = "assumed"
+	// AwsRoleStatusCached is the status of the role with cached credentials.
+	AwsRoleStatusCached = "cached"
+	// AwsRoleStatusFailed is the status of the role that STS did not let
+	// assume.
+	AwsRoleStatusFailed = "failed"
+	// AwsRoleStatusNotOffered is the status of the role missing in SAML
+	// assertions.
+	AwsRoleStatusNotOffered = "not_offered"
+)
+
+// GetResultFormats returns the supported formats of LoginResult.
+func GetResultFormats() []string {
+	return []string{ResultFormatJSON, ResultFormatYAML}
+}
+
+// LoginResult is the structure holding the outcome of a run, e.g. for
+// automation deciding what to do next.
+type LoginResult struct {
+	IdentityProvider string `json:"identity_provider" yaml:"identity_provider"`
+	Issuer           string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Username         string `json:"username,omitempty" yaml:"username,omitempty"`
+	SessionName      string `json:"session_name,omitempty" yaml:"session_name,omitempty"`
+	// OfferedRoles are the roles in SAML assertions.
+	OfferedRoles []*LoginResultRole `json:"offered_roles" yaml:"offered_roles"`
+	// Roles are the requested roles.
+	Roles []*LoginResultRole `json:"roles" yaml:"roles"`
+	// RoleErrors are the roles in SAML assertions that failed to parse.
+	RoleErrors []string `json:"role_errors,omitempty" yaml:"role_errors,omitempty"`
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// LoginResultRole is the structure holding an AWS role in LoginResult.
+type LoginResultRole struct {
+	AccountID           string `json:"account_id" yaml:"account_id"`
+	Name                string `json:"name" yaml:"name"`
+	RoleARN             string `json:"role_arn,omitempty" yaml:"role_arn,omitempty"`
+	IdentityProviderARN string `json:"principal_arn,omitempty" yaml:"principal_arn,omitempty"`
+	Status              string `json:"status,omitempty" yaml:"status,omitempty"`
+	AssumedRoleARN      string `json:"assumed_role_arn,omitempty" yaml:"assumed_role_arn,omitempty"`
+	ProfileName         string `json:"profile_name,omitempty" yaml:"profile_name,omitempty"`
+	Region              string `json:"region,omitempty" yaml:"region,omitempty"`
+	Expiration          string `json:"expiration,omitempty" yaml:"expiration,omitempty"`
+	Error               string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// GetIdentityProvider returns the kind of SAML IdP, i.e. `azure`, `adfs`,
+// or `static`.
+func (c *Client) GetIdentityProvider() string {
+	if c.Config.Azure.TenantID != "" {
+		return "azure"
+	}
+	if c.Config.Adfs.Hostname != "" {
+		return "adfs"
+	}
+	return "static"
+}
+
+// GetLoginResult returns the outcome of GetAwsCredentials. The error, if
+// any, is the error returned by GetAwsCredentials.
+func (c *Client) GetLoginResult(err error) *LoginResult {
+	r := &LoginResult{
+		IdentityProvider: c.GetIdentityProvider(),
+		Username:         c.Config.Username,
+		OfferedRoles:     []*LoginResultRole{},
+		Roles:            []*LoginResultRole{},
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	offeredRoles := []*AwsRole{}
+	if attrs := c.Runtime.Saml.Attributes; attrs != nil {
+		r.Issuer = attrs.Issuer
+		r.SessionName = attrs.Aws.SessionName
+		offeredRoles = attrs.Aws.Roles
+		for _, roleErr := range attrs.Aws.RoleErrors {
+			r.RoleErrors = append(r.RoleErrors, roleErr.Error())
+		}
+	}
+	for _, role := range offeredRoles {
+		r.OfferedRoles = append(r.OfferedRoles, &LoginResultRole{
+			AccountID:           role.AccountID,
+			Name:                role.Name,
+			RoleARN:             role.RoleARN,
+			IdentityProviderARN: role.IdentityProviderARN,
+		})
+	}
+	for _, configRole := range c.Config.Aws.Roles {
+		item := &LoginResultRole{
+			AccountID:   configRole.AccountID,
+			Name:        configRole.Name,
+			ProfileName: configRole.ProfileName,
+			Region:      configRole.DefaultRegion,
+		}
+		r.Roles = append(r.Roles, item)
+		for _, role := range offeredRoles {
+			if role.AccountID == configRole.AccountID && role.Name == configRole.Name {
+				item.RoleARN = role.RoleARN
+				item.IdentityProviderARN = role.IdentityProviderARN
+				break
+			}
+		}
+		if creds := c.getAwsCredentialsForRole(configRole); creds != nil {
+			item.Status = AwsRoleStatusAssumed
+			if c.isAwsRoleCached(configRole) {
+				item.Status = AwsRoleStatusCached
+			}
+			item.RoleARN = creds.RoleARN
+			if creds.Raw != nil && creds.Raw.AssumedRoleUser != nil {
+				item.AssumedRoleARN = creds.Raw.AssumedRoleUser.Arn
+			}
+			item.Region = creds.DefaultRegion
+			if !creds.Expiration.IsZero() {
+				item.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+			}
+			continue
+		}
+		if item.RoleARN == "" {
+			if c.Runtime.Saml.Attributes != nil {
+				item.Status = AwsRoleStatusNotOffered
+			}
+			continue
+		}
+		for _, roleErr := range c.Aws.Errors {
+			if roleErr.RoleARN == item.RoleARN {
+				item.Status = AwsRoleStatusFailed
+				item.Error = roleErr.Error()
+				break
+			}
+		}
+	}
+	return r
+}
+
+// getAwsCredentialsForRole returns the credentials of a requested role,
+// either assumed or cached.
+func (c *Client) getAwsCredentialsForRole(role *AwsConfigurationRole) *AwsCredentials {
+	for _, creds := range c.Aws.Credentials {
+		arn, err := parseAwsArn(creds.RoleARN)
+		if err != nil {
+			continue
+		}
+		if arn.AccountID == role.AccountID && arn.ResourceName == role.Name {
+			return creds
+		}
+	}
+	return nil
+}
+
+// Marshal returns the result in a format, i.e. `json` or `yaml`.
+func (r *LoginResult) Marshal(format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case ResultFormatJSON:
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	case ResultFormatYAML:
+		return yaml.Marshal(r)
+	}
+	return nil, fmt.Errorf("unsupported output format: %s, supported formats: %s",
+		format, strings.Join(GetResultFormats(), ", "))
+}
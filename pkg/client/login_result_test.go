@@ -0,0 +1,120 @@
+package client
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestGetLoginResult(t *testing.T) {
+	fp := path.Join("../../assets/tests", "saml2.response.roles.xml")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	var r SamlResponse
+	if err := xml.Unmarshal(content, &r); err != nil {
+		t.Fatalf("failed to unmarshal '%s': %v", fp, err)
+	}
+	attrs, err := r.GetAttributes()
+	if err != nil {
+		t.Fatalf("failed to get attributes of '%s': %v", fp, err)
+	}
+
+	c := New()
+	c.Config.Adfs.Hostname = "adfs.contoso.com"
+	c.Config.Username = "jsmith@contoso.com"
+	c.Runtime.Saml.Attributes = attrs
+	c.Config.Aws.Roles = []*AwsConfigurationRole{
+		{AccountID: "795318967487", Name: "Administrator", ProfileName: "admin"},
+		{AccountID: "795318967487", Name: "ReadOnly", ProfileName: "readonly"},
+		{AccountID: "399230634940", Name: "ReadOnly", ProfileName: "cached"},
+		{AccountID: "000000000001", Name: "Administrator", ProfileName: "other"},
+	}
+	c.Runtime.CachedRoles = []*AwsConfigurationRole{c.Config.Aws.Roles[2]}
+	expiration := time.Date(2019, 7, 17, 12, 18, 0, 0, time.UTC)
+	c.Aws.Credentials = []*AwsCredentials{
+		{
+			AccessKeyId: "CACHED", SecretAccessKey: "SECRET", SessionToken: "TOKEN",
+			RoleARN:       "arn:aws:iam::399230634940:role/ReadOnly",
+			ProfileName:   "cached",
+			DefaultRegion: "us-east-1",
+			Expiration:    expiration,
+			// The cached entry lacks the assumed role user.
+			Raw: &AwsStsResponse{},
+		},
+		{
+			AccessKeyId: "ASSUMED", SecretAccessKey: "SECRET", SessionToken: "TOKEN",
+			RoleARN:       "arn:aws:iam::795318967487:role/Administrator",
+			ProfileName:   "admin",
+			DefaultRegion: "us-west-2",
+			Expiration:    expiration,
+			Raw: &AwsStsResponse{AssumedRoleUser: &AssumedRoleUser{
+				Arn: "arn:aws:sts::795318967487:assumed-role/Administrator/jsmith@contoso.com",
+			}},
+		},
+	}
+	c.Aws.Errors = []*AwsAssumeRoleError{
+		{
+			RoleARN:     "arn:aws:iam::795318967487:role/ReadOnly",
+			ProfileName: "readonly",
+			Err:         fmt.Errorf("AccessDenied"),
+		},
+	}
+
+	result := c.GetLoginResult(nil)
+	if result.IdentityProvider != "adfs" || result.Username != "jsmith@contoso.com" || result.SessionName == "" {
+		t.Fatalf("FAIL: unexpected identity in result: %+v", result)
+	}
+	if len(result.OfferedRoles) != 3 || len(result.RoleErrors) != 2 {
+		t.Fatalf("FAIL: expected 3 offered roles and 2 role errors, but got %d and %d",
+			len(result.OfferedRoles), len(result.RoleErrors))
+	}
+	testFailed := 0
+	for i, test := range []struct {
+		status      string
+		region      string
+		expiration  string
+		assumedRole string
+		err         string
+	}{
+		{
+			status: AwsRoleStatusAssumed, region: "us-west-2", expiration: "2019-07-17T12:18:00Z",
+			assumedRole: "arn:aws:sts::795318967487:assumed-role/Administrator/jsmith@contoso.com",
+		},
+		{status: AwsRoleStatusFailed, err: "AccessDenied"},
+		{status: AwsRoleStatusCached, region: "us-east-1", expiration: "2019-07-17T12:18:00Z"},
+		{status: AwsRoleStatusNotOffered},
+	} {
+		role := result.Roles[i]
+		if role.Status != test.status || role.Region != test.region || role.Expiration != test.expiration ||
+			role.AssumedRoleARN != test.assumedRole || role.Error != test.err {
+			t.Logf("FAIL: Test %d: profile '%s', unexpected result: %+v", i, role.ProfileName, role)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: profile '%s', expected to pass, passed", i, role.ProfileName)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+
+	b, err := result.Marshal(ResultFormatJSON)
+	if err != nil {
+		t.Fatalf("failed marshaling result: %v", err)
+	}
+	parsed := &LoginResult{}
+	if err := json.Unmarshal(b, parsed); err != nil || len(parsed.Roles) != 4 {
+		t.Fatalf("FAIL: unexpected JSON result: %s (%v)", b, err)
+	}
+	if _, err := result.Marshal(ResultFormatYAML); err != nil {
+		t.Fatalf("failed marshaling result: %v", err)
+	}
+	if _, err := result.Marshal("xml"); err == nil {
+		t.Fatalf("FAIL: expected unsupported format to fail")
+	}
+}
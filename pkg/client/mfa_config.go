@@ -0,0 +1,90 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"time"
+)
+
+// The methods of multi-factor authentication.
+const (
+	// MfaMethodPush is the approval of a push notification, e.g. in
+	// Microsoft Authenticator.
+	MfaMethodPush = "push"
+	// MfaMethodTotp is the code from an authenticator app.
+	MfaMethodTotp = "totp"
+	// MfaMethodSms is the code sent in a text message.
+	MfaMethodSms = "sms"
+	// MfaMethodVoice is the approval of a phone call.
+	MfaMethodVoice = "voice"
+)
+
+// DefaultMfaTimeout is the time the client waits for a user to approve
+// the sign-in, e.g. a push notification.
+const DefaultMfaTimeout = time.Minute
+
+// mfaPollInterval is the interval between the checks whether a user
+// approved the sign-in.
+var mfaPollInterval = 2 * time.Second
+
+// MfaConfiguration is the configuration of multi-factor authentication.
+type MfaConfiguration struct {
+	Method  string        `xml:"method,attr" json:"method" yaml:"method"`
+	Timeout time.Duration `xml:"timeout,attr" json:"timeout" yaml:"timeout"`
+}
+
+// GetMfaMethods returns the supported methods of multi-factor
+// authentication.
+func GetMfaMethods() []string {
+	return []string{MfaMethodPush, MfaMethodTotp, MfaMethodSms, MfaMethodVoice}
+}
+
+// SetMfaMethod sets the preferred method of multi-factor authentication.
+func (c *Client) SetMfaMethod(s string) error {
+	for _, m := range GetMfaMethods() {
+		if s == m {
+			c.Config.Mfa.Method = s
+			log.Debugf("Preferred MFA method: %s", s)
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported MFA method: %s, supported: %s", s, strings.Join(GetMfaMethods(), ", "))
+}
+
+// SetMfaTimeout sets the time the client waits for a user to approve the
+// sign-in.
+func (c *Client) SetMfaTimeout(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("MFA timeout must be positive: %s", d)
+	}
+	c.Config.Mfa.Timeout = d
+	return nil
+}
+
+func (c *Client) getMfaTimeout() time.Duration {
+	if c.Config.Mfa.Timeout == 0 {
+		return DefaultMfaTimeout
+	}
+	return c.Config.Mfa.Timeout
+}
+
+// promptMfaCode prompts a user for the verification code of multi-factor
+// authentication.
+func (c *Client) promptMfaCode(prompt string) (string, error) {
+	if c.mfaCodePrompt != nil {
+		return c.mfaCodePrompt(prompt)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	v, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("Erred when processing user input: %s", err)
+	}
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", fmt.Errorf("No user input")
+	}
+	return v, nil
+}
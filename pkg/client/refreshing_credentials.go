@@ -0,0 +1,150 @@
+package client
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// DefaultServeRefreshWindow is the time before the expiration of served
+// AWS credentials when the credentials are refreshed. It exceeds the
+// windows used by AWS SDKs, so that the SDKs receive fresh credentials.
+const DefaultServeRefreshWindow = 15 * time.Minute
+
+// refreshRetryInterval is the minimum interval between the attempts to
+// refresh AWS credentials, e.g. after a failure.
+const refreshRetryInterval = time.Minute
+
+// RefreshingAwsCredentials holds AWS credentials of a role and obtains new
+// credentials shortly before the current ones expire.
+type RefreshingAwsCredentials struct {
+	refresh       func() (*AwsCredentials, error)
+	RefreshWindow time.Duration
+	mu            sync.Mutex
+	credentials   *AwsCredentials
+	lastAttempt   time.Time
+}
+
+// NewRefreshingAwsCredentials returns RefreshingAwsCredentials instance
+// obtaining the credentials with the refresh function.
+func NewRefreshingAwsCredentials(refresh func() (*AwsCredentials, error)) *RefreshingAwsCredentials {
+	return &RefreshingAwsCredentials{
+		refresh:       refresh,
+		RefreshWindow: DefaultServeRefreshWindow,
+	}
+}
+
+// NewRefreshingAwsCredentials returns RefreshingAwsCredentials instance
+// running SAML authentication and STS request for the requested role
+// whenever the credentials are about to expire. The refresh bypasses the
+// credential cache, which would return the expiring credentials until
+// its own, shorter, refresh window.
+func (c *Client) NewRefreshingAwsCredentials() *RefreshingAwsCredentials {
+	return NewRefreshingAwsCredentials(func() (*AwsCredentials, error) {
+		creds, err := c.RefreshAwsCredentials()
+		if err != nil {
+			return nil, err
+		}
+		if len(creds) != 1 {
+			return nil, fmt.Errorf("expected one set of AWS credentials, received %d", len(creds))
+		}
+		return creds[0], nil
+	})
+}
+
+// Set sets the current credentials, e.g. the credentials obtained at
+// startup.
+func (r *RefreshingAwsCredentials) Set(creds *AwsCredentials) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.credentials = creds
+	r.lastAttempt = time.Now()
+}
+
+// Get returns the current credentials. The credentials are refreshed when
+// they are about to expire. When the refresh fails, the current
+// credentials are returned until they expire.
+func (r *RefreshingAwsCredentials) Get() (*AwsCredentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.credentials != nil && !r.needsRefresh(time.Now()) {
+		return r.credentials, nil
+	}
+	if err := r.refreshLocked(); err != nil {
+		if r.credentials != nil && time.Now().Before(r.credentials.Expiration) {
+			log.Warnf("Failed to refresh AWS credentials, using the current ones until %s: %s",
+				r.credentials.Expiration.Format(time.RFC3339), err)
+			return r.credentials, nil
+		}
+		return nil, err
+	}
+	return r.credentials, nil
+}
+
+// Run refreshes the credentials in background before they expire, so that
+// the requests for the credentials do not wait for the authentication. It
+// returns when the stop channel is closed.
+func (r *RefreshingAwsCredentials) Run(stop <-chan struct{}) {
+	for {
+		r.mu.Lock()
+		wait := refreshRetryInterval
+		if r.credentials != nil && !r.credentials.Expiration.IsZero() {
+			wait = time.Until(r.nextRefresh())
+		}
+		r.mu.Unlock()
+		if wait > 0 {
+			log.Debugf("Refreshing AWS credentials in %s", wait.Round(time.Second))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		r.mu.Lock()
+		if r.credentials == nil || r.needsRefresh(time.Now()) {
+			if err := r.refreshLocked(); err != nil {
+				log.Errorf("Failed to refresh AWS credentials, retrying in %s: %s", refreshRetryInterval, err)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// nextRefresh returns the time when the current credentials are refreshed,
+// i.e. the start of the refresh window. The refresh is not attempted more
+// often than once in refreshRetryInterval, e.g. when IdP is unavailable, or
+// when the credentials are issued for less than the refresh window.
+func (r *RefreshingAwsCredentials) nextRefresh() time.Time {
+	t := r.credentials.Expiration.Add(-1 * r.RefreshWindow)
+	if earliest := r.lastAttempt.Add(refreshRetryInterval); t.Before(earliest) {
+		return earliest
+	}
+	return t
+}
+
+// needsRefresh checks whether the current credentials are due for refresh.
+// The credentials without expiration are never refreshed.
+func (r *RefreshingAwsCredentials) needsRefresh(now time.Time) bool {
+	if r.credentials.Expiration.IsZero() {
+		return false
+	}
+	return !now.Before(r.nextRefresh())
+}
+
+func (r *RefreshingAwsCredentials) refreshLocked() error {
+	r.lastAttempt = time.Now()
+	creds, err := r.refresh()
+	if err != nil {
+		return err
+	}
+	if creds.Expiration.IsZero() {
+		log.Warnf("The refreshed AWS credentials have no expiration, they will not be refreshed again")
+	} else {
+		log.Infof("Refreshed AWS credentials, valid until %s", creds.Expiration.Format(time.RFC3339))
+	}
+	r.credentials = creds
+	return nil
+}
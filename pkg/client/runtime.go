@@ -8,13 +8,16 @@ type StateMachine struct {
 	Metadata          SamlServiceMetadata
 	AuthenticationURL string `xml:"auth_url,attr" json:"auth_url" yaml:"auth_url"`
 	Saml              SamlStateMachine
+	// CachedRoles are the requested roles with cached credentials.
+	CachedRoles []*AwsConfigurationRole
 }
 
 type SamlServiceMetadata struct {
-	Raw   []byte
-	Plain string
-	File  File
-	URL   string
+	Raw    []byte
+	Plain  string
+	File   File
+	URL    string
+	Entity *EntityDescriptor
 }
 
 type SamlResponseAssertions struct {
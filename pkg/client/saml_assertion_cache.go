@@ -0,0 +1,158 @@
+package client
+
+import (
+	"encoding/xml"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// samlAssertionReuseMargin is the time SAML assertions must remain valid
+// for to be reused, i.e. to reach AWS STS before they expire.
+const samlAssertionReuseMargin = 30 * time.Second
+
+// getSamlAssertionCacheKey returns the key of the cached SAML assertions of
+// the current identity at IdP.
+func (c *Client) getSamlAssertionCacheKey() string {
+	return "saml\n" + c.GetIdentity()
+}
+
+// isSamlAssertionCacheEnabled checks whether SAML assertions are cached.
+// The assertions from static file are never cached.
+func (c *Client) isSamlAssertionCacheEnabled() bool {
+	if !c.Config.Cache.Enabled || c.Config.Username == "" {
+		return false
+	}
+	return c.Config.Azure.TenantID != "" || c.Config.Adfs.Hostname != ""
+}
+
+// GetSamlAssertionsExpiration returns the time when the received SAML
+// assertions stop being accepted, i.e. the earliest of the end of the
+// subject confirmation and the end of the conditions. It returns zero
+// time when the assertions have neither.
+func (c *Client) GetSamlAssertionsExpiration() time.Time {
+	var expiration time.Time
+	if c.Runtime.Saml.Attributes == nil {
+		return expiration
+	}
+	for _, t := range []time.Time{
+		c.Runtime.Saml.Attributes.Aws.AuthenticateByTimestamp,
+		c.Runtime.Saml.Attributes.Aws.SessionEndTimestamp,
+	} {
+		if !t.IsZero() && (expiration.IsZero() || t.Before(expiration)) {
+			expiration = t
+		}
+	}
+	return expiration
+}
+
+// GetCachedSamlAssertions reads the cached SAML assertions of the current
+// identity at IdP. It returns true when the assertions are valid for long
+// enough to be sent to AWS STS, i.e. there is no need to authenticate to
+// IdP again. The assertions are loaded into the runtime state. The
+// signature is not verified again, because it was verified before the
+// assertions were cached, and the cache entry is authenticated.
+func (c *Client) GetCachedSamlAssertions() bool {
+	if !c.isSamlAssertionCacheEnabled() {
+		return false
+	}
+	if c.Runtime.Saml.Attributes != nil && c.isSamlAssertionReusable() {
+		return true
+	}
+	key := c.getSamlAssertionCacheKey()
+	fp := c.getCacheFilePath(key, ".saml.enc")
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debugf("Failed to read cached SAML assertions from %s: %s", fp, err)
+		}
+		return false
+	}
+	raw, err := c.getCredentialCacheEncryptor().Open(data, []byte(key))
+	if err != nil {
+		log.Debugf("Failed to decrypt cached SAML assertions in %s: %s", fp, err)
+		return false
+	}
+	if err := c.loadSamlAssertions(raw); err != nil {
+		log.Debugf("Failed to parse cached SAML assertions in %s: %s", fp, err)
+		c.resetSamlAssertions()
+		return false
+	}
+	if !c.isSamlAssertionReusable() {
+		log.Debugf("Cached SAML assertions in %s expired at %s", fp, c.GetSamlAssertionsExpiration())
+		c.resetSamlAssertions()
+		os.Remove(fp)
+		return false
+	}
+	if err := c.IsSamlAssertionValid(); err != nil {
+		log.Debugf("Cached SAML assertions in %s are invalid: %s", fp, err)
+		c.resetSamlAssertions()
+		return false
+	}
+	log.Debugf("Using cached SAML assertions, valid until %s", c.GetSamlAssertionsExpiration())
+	return true
+}
+
+// CacheSamlAssertions writes the received SAML assertions to the cache.
+func (c *Client) CacheSamlAssertions() error {
+	if c.Runtime.Saml.Assertions == nil || len(c.Runtime.Saml.Assertions.Raw) == 0 {
+		return fmt.Errorf("no SAML assertions to cache")
+	}
+	if c.GetSamlAssertionsExpiration().IsZero() {
+		return fmt.Errorf("the SAML assertions have no expiration")
+	}
+	key := c.getSamlAssertionCacheKey()
+	data, err := c.getCredentialCacheEncryptor().Seal(c.Runtime.Saml.Assertions.Raw, []byte(key))
+	if err != nil {
+		return err
+	}
+	fp := c.getCacheFilePath(key, ".saml.enc")
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fp, data, 0600); err != nil {
+		return err
+	}
+	log.Debugf("Cached SAML assertions in %s until %s", fp, c.GetSamlAssertionsExpiration())
+	return nil
+}
+
+// isSamlAssertionReusable checks whether the SAML assertions in the runtime
+// state remain valid for long enough to be sent to AWS STS.
+func (c *Client) isSamlAssertionReusable() bool {
+	expiration := c.GetSamlAssertionsExpiration()
+	if expiration.IsZero() {
+		return false
+	}
+	return time.Now().Add(samlAssertionReuseMargin).Before(expiration)
+}
+
+// loadSamlAssertions parses raw SAML response into the runtime state.
+func (c *Client) loadSamlAssertions(raw []byte) error {
+	c.Runtime.Saml.Assertions = &SamlResponseAssertions{
+		Raw:   raw,
+		Plain: string(raw),
+	}
+	c.Runtime.Saml.Response = SamlResponse{}
+	if err := xml.Unmarshal(raw, &c.Runtime.Saml.Response); err != nil {
+		return err
+	}
+	if c.Runtime.Saml.Response.Assertion.AttributeStatement == nil {
+		return fmt.Errorf("SAML Response does not contain attribute statements")
+	}
+	attrs, err := c.Runtime.Saml.Response.GetAttributes()
+	if err != nil {
+		return err
+	}
+	c.Runtime.Saml.Attributes = attrs
+	return nil
+}
+
+func (c *Client) resetSamlAssertions() {
+	c.Runtime.Saml.Assertions = nil
+	c.Runtime.Saml.Attributes = nil
+	c.Runtime.Saml.Response = SamlResponse{}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getTestSamlResponse returns SAML response with the subject confirmation
+// ending at a time.
+func getTestSamlResponse(t *testing.T, notOnOrAfter time.Time) []byte {
+	fp := path.Join("../../assets/tests", "saml2.response.roles.xml")
+	content, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("failed reading '%s', error: %v", fp, err)
+	}
+	now := time.Now().UTC()
+	for re, ts := range map[string]time.Time{
+		`(Conditions NotBefore)="[^"]*"`:                      now.Add(-5 * time.Minute),
+		`(Conditions NotBefore="[^"]*" NotOnOrAfter)="[^"]*"`: now.Add(time.Hour),
+		`(SubjectConfirmationData NotOnOrAfter)="[^"]*"`:      notOnOrAfter,
+		`((Issue|Authn)Instant)="[^"]*"`:                      now.Add(-time.Minute),
+	} {
+		// Only the timestamp at the end of a match is replaced.
+		content = regexp.MustCompile(re).ReplaceAllFunc(content, func(m []byte) []byte {
+			i := strings.LastIndex(string(m), `="`)
+			return []byte(string(m[:i]) + `="` + ts.Format(time.RFC3339) + `"`)
+		})
+	}
+	return content
+}
+
+func TestSamlAssertionCache(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ggk-saml-cache")
+	if err != nil {
+		t.Fatalf("failed creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	testFailed := 0
+	for i, test := range []struct {
+		passphrase     string
+		readPassphrase string
+		username       string
+		expiresIn      time.Duration
+		isCached       bool
+	}{
+		{passphrase: "secret", readPassphrase: "secret", expiresIn: 5 * time.Minute, isCached: true},
+		{passphrase: "secret", readPassphrase: "other", expiresIn: 5 * time.Minute, isCached: false},
+		// The assertions expiring within the margin are not reused.
+		{passphrase: "secret", readPassphrase: "secret", expiresIn: 10 * time.Second, isCached: false},
+		// The assertions of another user are not reused.
+		{passphrase: "secret", readPassphrase: "secret", username: "other@contoso.com", expiresIn: 5 * time.Minute, isCached: false},
+	} {
+		cli := New()
+		cli.Config.Adfs.Hostname = "adfs.contoso.com"
+		cli.Config.Username = "jsmith@contoso.com"
+		cli.Config.Cache.Enabled = true
+		cli.Config.Cache.Dir = path.Join(tmpDir, "cache")
+		cli.Config.Cache.KeyFile = path.Join(tmpDir, "cache.key")
+		cli.Config.Cache.Passphrase = test.passphrase
+		if err := cli.loadSamlAssertions(getTestSamlResponse(t, time.Now().Add(test.expiresIn))); err != nil {
+			t.Fatalf("FAIL: Test %d: failed loading SAML assertions: %v", i, err)
+		}
+		if err := cli.CacheSamlAssertions(); err != nil {
+			t.Fatalf("FAIL: Test %d: failed caching SAML assertions: %v", i, err)
+		}
+
+		cli = New()
+		cli.Config.Adfs.Hostname = "adfs.contoso.com"
+		cli.Config.Username = "jsmith@contoso.com"
+		if test.username != "" {
+			cli.Config.Username = test.username
+		}
+		cli.Config.Cache.Enabled = true
+		cli.Config.Cache.Dir = path.Join(tmpDir, "cache")
+		cli.Config.Cache.KeyFile = path.Join(tmpDir, "cache.key")
+		cli.Config.Cache.Passphrase = test.readPassphrase
+		isCached := cli.GetCachedSamlAssertions()
+		if isCached != test.isCached {
+			t.Logf("FAIL: Test %d: cached mismatch %t (expected) vs %t", i, test.isCached, isCached)
+			testFailed++
+			continue
+		}
+		if isCached && len(cli.GetAwsRoleList()) != 3 {
+			t.Logf("FAIL: Test %d: cached SAML assertions have no roles", i)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: cached %t, expected to pass, passed", i, isCached)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
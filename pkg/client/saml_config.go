@@ -0,0 +1,13 @@
+package client
+
+import (
+	"time"
+)
+
+type SamlConfiguration struct {
+	VerifySignature bool          `xml:"verify_signature,attr" json:"verify_signature" yaml:"verify_signature"`
+	MetadataFile    string        `xml:"metadata_file,attr" json:"metadata_file" yaml:"metadata_file"`
+	MetadataMaxAge  time.Duration `xml:"metadata_max_age,attr" json:"metadata_max_age" yaml:"metadata_max_age"`
+	RefreshMetadata bool          `xml:"refresh_metadata,attr" json:"refresh_metadata" yaml:"refresh_metadata"`
+	ClockSkew       time.Duration `xml:"clock_skew,attr" json:"clock_skew" yaml:"clock_skew"`
+}
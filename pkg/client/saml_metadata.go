@@ -0,0 +1,212 @@
+package client
+
+import (
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SamlHTTPRedirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	SamlHTTPPostBinding     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// EntityDescriptor is the structure holding SAMLv2 metadata of an entity,
+// e.g. the content of FederationMetadata.xml of ADFS or Azure AD.
+type EntityDescriptor struct {
+	XMLName          xml.Name            `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	ID               string              `xml:"ID,attr,omitempty"`
+	EntityID         string              `xml:"entityID,attr"`
+	ValidUntil       time.Time           `xml:"validUntil,attr,omitempty"`
+	CacheDuration    string              `xml:"cacheDuration,attr,omitempty"`
+	RoleDescriptors  []RoleDescriptor    `xml:"urn:oasis:names:tc:SAML:2.0:metadata RoleDescriptor"`
+	IDPSSODescriptor []*IDPSSODescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+// RoleDescriptor is the structure holding the metadata of a role other than
+// IdP, e.g. WS-Federation security token service.
+type RoleDescriptor struct {
+	Type                       string          `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	ProtocolSupportEnumeration string          `xml:"protocolSupportEnumeration,attr"`
+	KeyDescriptors             []KeyDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+}
+
+// IDPSSODescriptor is the structure holding the metadata of SAMLv2 IdP.
+type IDPSSODescriptor struct {
+	ProtocolSupportEnumeration string             `xml:"protocolSupportEnumeration,attr"`
+	ValidUntil                 time.Time          `xml:"validUntil,attr,omitempty"`
+	CacheDuration              string             `xml:"cacheDuration,attr,omitempty"`
+	KeyDescriptors             []KeyDescriptor    `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SingleLogoutServices       []MetadataEndpoint `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleLogoutService"`
+	NameIDFormats              []string           `xml:"urn:oasis:names:tc:SAML:2.0:metadata NameIDFormat"`
+	SingleSignOnServices       []MetadataEndpoint `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+// MetadataEndpoint is the structure holding the binding and the location
+// of a service, e.g. SingleSignOnService.
+type MetadataEndpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// KeyDescriptor is the structure holding the certificates of an entity
+// together with their use, i.e. signing or encryption.
+type KeyDescriptor struct {
+	Use     string `xml:"use,attr,omitempty"`
+	KeyInfo struct {
+		X509Data struct {
+			X509Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# X509Certificate"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# X509Data"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+}
+
+// NewEntityDescriptorFromString returns EntityDescriptor instance from an input string.
+func NewEntityDescriptorFromString(s string) (*EntityDescriptor, error) {
+	return NewEntityDescriptorFromBytes([]byte(s))
+}
+
+// NewEntityDescriptorFromBytes returns EntityDescriptor instance from an input byte array.
+func NewEntityDescriptorFromBytes(s []byte) (*EntityDescriptor, error) {
+	e := &EntityDescriptor{}
+	if err := xml.Unmarshal(s, e); err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata: %s", err)
+	}
+	if e.EntityID == "" {
+		return nil, fmt.Errorf("Failed to parse metadata: entityID not found")
+	}
+	if len(e.IDPSSODescriptor) == 0 {
+		return nil, fmt.Errorf("Failed to parse metadata: IDPSSODescriptor not found for %s", e.EntityID)
+	}
+	if _, err := e.GetCacheDuration(); err != nil {
+		return nil, fmt.Errorf("Failed to parse metadata: %s", err)
+	}
+	return e, nil
+}
+
+// GetSingleSignOnService returns the location of IdP single sign-on
+// service. The services with HTTP-Redirect binding are preferred over
+// the ones with HTTP-POST binding.
+func (e *EntityDescriptor) GetSingleSignOnService() (*MetadataEndpoint, error) {
+	for _, binding := range []string{SamlHTTPRedirectBinding, SamlHTTPPostBinding} {
+		for _, descriptor := range e.IDPSSODescriptor {
+			for _, endpoint := range descriptor.SingleSignOnServices {
+				if endpoint.Binding == binding && endpoint.Location != "" {
+					return &endpoint, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("SingleSignOnService not found in metadata for %s", e.EntityID)
+}
+
+// GetCertificates returns IdP certificates for a particular use, i.e.
+// signing or encryption. The certificates without use are suitable
+// for both.
+func (e *EntityDescriptor) GetCertificates(use string) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	fingerprints := map[string]bool{}
+	for _, descriptor := range e.IDPSSODescriptor {
+		for _, keyDescriptor := range descriptor.KeyDescriptors {
+			if keyDescriptor.Use != "" && keyDescriptor.Use != use {
+				continue
+			}
+			for _, s := range keyDescriptor.KeyInfo.X509Data.X509Certificates {
+				b, err := decodeXMLBase64(s)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to decode %s certificate in metadata: %s", use, err)
+				}
+				if fingerprints[string(b)] {
+					continue
+				}
+				cert, err := x509.ParseCertificate(b)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to parse %s certificate in metadata: %s", use, err)
+				}
+				fingerprints[string(b)] = true
+				certs = append(certs, cert)
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No %s certificates found in metadata for %s", use, e.EntityID)
+	}
+	return certs, nil
+}
+
+// GetValidUntil returns the earliest expiration of the metadata. It returns
+// zero time when the metadata has no expiration.
+func (e *EntityDescriptor) GetValidUntil() time.Time {
+	validUntil := e.ValidUntil
+	for _, descriptor := range e.IDPSSODescriptor {
+		if descriptor.ValidUntil.IsZero() {
+			continue
+		}
+		if validUntil.IsZero() || descriptor.ValidUntil.Before(validUntil) {
+			validUntil = descriptor.ValidUntil
+		}
+	}
+	return validUntil
+}
+
+// GetCacheDuration returns the shortest cache duration of the metadata. It
+// returns zero when the metadata has no cache duration.
+func (e *EntityDescriptor) GetCacheDuration() (time.Duration, error) {
+	durations := []string{e.CacheDuration}
+	for _, descriptor := range e.IDPSSODescriptor {
+		durations = append(durations, descriptor.CacheDuration)
+	}
+	var cacheDuration time.Duration
+	for _, s := range durations {
+		if s == "" {
+			continue
+		}
+		d, err := ParseXMLDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		if cacheDuration == 0 || d < cacheDuration {
+			cacheDuration = d
+		}
+	}
+	return cacheDuration, nil
+}
+
+var xmlDurationRegex = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseXMLDuration parses xs:duration value, e.g. PT1H or P1DT12H. The
+// years and months are approximated with 365 and 30 days.
+func ParseXMLDuration(s string) (time.Duration, error) {
+	m := xmlDurationRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{
+		365 * 24 * time.Hour,
+		30 * 24 * time.Hour,
+		24 * time.Hour,
+		time.Hour,
+		time.Minute,
+	} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[6] != "" {
+		f, err := strconv.ParseFloat(m[6], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		d += time.Duration(f * float64(time.Second))
+	}
+	return d, nil
+}
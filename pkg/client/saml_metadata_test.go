@@ -0,0 +1,135 @@
+package client
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestParseEntityDescriptor(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		input           string
+		entityID        string
+		ssoLocation     string
+		signingCerts    int
+		encryptionCerts int
+		cacheDuration   time.Duration
+		shouldErr       bool
+	}{
+		{
+			input:           "adfs.federation.metadata.xml",
+			entityID:        "http://adfs.contoso.com/adfs/services/trust",
+			ssoLocation:     "https://adfs.contoso.com/federation/ls/",
+			signingCerts:    1,
+			encryptionCerts: 1,
+			cacheDuration:   24 * time.Hour,
+			shouldErr:       false,
+		},
+		{
+			input:     "saml2.response.xml",
+			shouldErr: true,
+		},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		entity, err := NewEntityDescriptorFromBytes(content)
+		if err != nil {
+			if !test.shouldErr {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input '%s', expected to throw error, threw: %v", i, test.input, err)
+			continue
+		}
+		if test.shouldErr {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw error, but passed: %v", i, test.input, entity)
+			testFailed++
+			continue
+		}
+		if entity.EntityID != test.entityID {
+			t.Logf("FAIL: Test %d: input '%s', entityID mismatch %s (expected) vs %s (file)",
+				i, test.input, test.entityID, entity.EntityID)
+			testFailed++
+			continue
+		}
+		endpoint, err := entity.GetSingleSignOnService()
+		if err != nil || endpoint.Location != test.ssoLocation {
+			t.Logf("FAIL: Test %d: input '%s', SingleSignOnService mismatch %s (expected) vs %v (file), error: %v",
+				i, test.input, test.ssoLocation, endpoint, err)
+			testFailed++
+			continue
+		}
+		for use, expected := range map[string]int{"signing": test.signingCerts, "encryption": test.encryptionCerts} {
+			certs, err := entity.GetCertificates(use)
+			if err != nil || len(certs) != expected {
+				t.Logf("FAIL: Test %d: input '%s', %s certificate count mismatch %d (expected) vs %d (file), error: %v",
+					i, test.input, use, expected, len(certs), err)
+				testFailed++
+			}
+		}
+		cacheDuration, err := entity.GetCacheDuration()
+		if err != nil || cacheDuration != test.cacheDuration {
+			t.Logf("FAIL: Test %d: input '%s', cacheDuration mismatch %s (expected) vs %s (file), error: %v",
+				i, test.input, test.cacheDuration, cacheDuration, err)
+			testFailed++
+			continue
+		}
+		if entity.GetValidUntil().IsZero() {
+			t.Logf("FAIL: Test %d: input '%s', validUntil not found", i, test.input)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestParseXMLDuration(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		input     string
+		exp       time.Duration
+		shouldErr bool
+	}{
+		{input: "PT1H", exp: time.Hour},
+		{input: "P1DT12H", exp: 36 * time.Hour},
+		{input: "PT30M15.5S", exp: 30*time.Minute + 15500*time.Millisecond},
+		{input: "P", shouldErr: true},
+		{input: "PT", shouldErr: true},
+		{input: "1H", shouldErr: true},
+	} {
+		d, err := ParseXMLDuration(test.input)
+		if err != nil {
+			if !test.shouldErr {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+			}
+			continue
+		}
+		if test.shouldErr {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw error, but passed: %s", i, test.input, d)
+			testFailed++
+			continue
+		}
+		if d != test.exp {
+			t.Logf("FAIL: Test %d: input '%s', mismatch %s (expected) vs %s", i, test.input, test.exp, d)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
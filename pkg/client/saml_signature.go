@@ -0,0 +1,227 @@
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"hash"
+	"strings"
+)
+
+const (
+	SamlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	SamlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	XMLDsigNamespace       = "http://www.w3.org/2000/09/xmldsig#"
+	XMLExcC14NNamespace    = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+	XMLExcC14NAlgorithm            = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	XMLEnvelopedSignatureTransform = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	XMLDsigRsaSha1Algorithm        = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	XMLDsigRsaSha256Algorithm      = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	XMLDsigRsaSha512Algorithm      = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	XMLDsigSha1Algorithm           = "http://www.w3.org/2000/09/xmldsig#sha1"
+	XMLDsigSha256Algorithm         = "http://www.w3.org/2001/04/xmlenc#sha256"
+	XMLDsigSha512Algorithm         = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+// VerifySamlSignature verifies the XML signature of SAML Response with the
+// signing certificates found in IdP metadata. The verification takes place
+// only when it is enabled in the configuration.
+func (c *Client) VerifySamlSignature() error {
+	if !c.Config.Saml.VerifySignature {
+		log.Debugf("SAML Response signature verification is disabled")
+		return nil
+	}
+	if c.Runtime.Saml.Assertions == nil || len(c.Runtime.Saml.Assertions.Raw) == 0 {
+		return fmt.Errorf("SAML Response signature verification failed: SAML Response not found")
+	}
+	if len(c.Runtime.Metadata.Raw) == 0 {
+		return fmt.Errorf("SAML Response signature verification failed: IdP metadata not found")
+	}
+	certs, err := c.Runtime.Metadata.GetSigningCertificates()
+	if err != nil {
+		return fmt.Errorf("SAML Response signature verification failed: %s", err)
+	}
+	if err := VerifySamlResponseSignature(c.Runtime.Saml.Assertions.Raw, certs); err != nil {
+		return fmt.Errorf("SAML Response signature verification failed: %s", err)
+	}
+	log.Debugf("SAML Response signature is valid")
+	return nil
+}
+
+// VerifySamlResponseSignature verifies enveloped XML signatures of SAML
+// Response and its Assertion with the provided certificates. The Assertion
+// must be covered by at least one valid signature, either its own or the
+// signature of the Response.
+func VerifySamlResponseSignature(s []byte, certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("no signing certificates")
+	}
+	root, err := parseXMLDocument(s)
+	if err != nil {
+		return err
+	}
+	if !root.Is(SamlProtocolNamespace, "Response") {
+		return fmt.Errorf("the root element is not SAML Response")
+	}
+	// The document must have exactly one assertion. Otherwise, the signed
+	// assertion might not be the one being used, i.e. signature wrapping.
+	if n := len(root.FindElements(SamlAssertionNamespace, "Assertion")); n != 1 {
+		return fmt.Errorf("SAML Response is expected to have one Assertion, but it has %d", n)
+	}
+	assertion := root.ChildElement(SamlAssertionNamespace, "Assertion")
+	if assertion == nil {
+		return fmt.Errorf("SAML Response has no Assertion")
+	}
+	isAssertionSigned := false
+	for _, e := range []*xmlElement{root, assertion} {
+		signatures := e.ChildElements(XMLDsigNamespace, "Signature")
+		if len(signatures) == 0 {
+			continue
+		}
+		if len(signatures) > 1 {
+			return fmt.Errorf("%s has %d signatures", e.Local, len(signatures))
+		}
+		if err := verifyEnvelopedSignature(e, signatures[0], certs); err != nil {
+			return fmt.Errorf("%s: %s", e.Local, err)
+		}
+		isAssertionSigned = true
+	}
+	if !isAssertionSigned {
+		return fmt.Errorf("neither SAML Response nor Assertion is signed")
+	}
+	return nil
+}
+
+// verifyEnvelopedSignature verifies the signature enveloped in an element.
+func verifyEnvelopedSignature(e, signature *xmlElement, certs []*x509.Certificate) error {
+	signedInfo := signature.ChildElement(XMLDsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return fmt.Errorf("signature has no SignedInfo")
+	}
+	c14nMethod := signedInfo.ChildElement(XMLDsigNamespace, "CanonicalizationMethod")
+	if c14nMethod == nil {
+		return fmt.Errorf("signature has no CanonicalizationMethod")
+	}
+	if c14nMethod.Attr("Algorithm") != XMLExcC14NAlgorithm {
+		return fmt.Errorf("unsupported canonicalization method: %s", c14nMethod.Attr("Algorithm"))
+	}
+	signatureMethod := signedInfo.ChildElement(XMLDsigNamespace, "SignatureMethod")
+	if signatureMethod == nil {
+		return fmt.Errorf("signature has no SignatureMethod")
+	}
+	var signatureHash crypto.Hash
+	switch signatureMethod.Attr("Algorithm") {
+	case XMLDsigRsaSha1Algorithm:
+		signatureHash = crypto.SHA1
+	case XMLDsigRsaSha256Algorithm:
+		signatureHash = crypto.SHA256
+	case XMLDsigRsaSha512Algorithm:
+		signatureHash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signature method: %s", signatureMethod.Attr("Algorithm"))
+	}
+
+	references := signedInfo.ChildElements(XMLDsigNamespace, "Reference")
+	if len(references) != 1 {
+		return fmt.Errorf("signature is expected to have one Reference, but it has %d", len(references))
+	}
+	reference := references[0]
+	id := e.Attr("ID")
+	if id == "" || reference.Attr("URI") != "#"+id {
+		return fmt.Errorf("signature reference %s does not match element ID %s", reference.Attr("URI"), id)
+	}
+
+	// Apply the transforms to the referenced element.
+	isEnveloped := false
+	var inclusivePrefixes []string
+	if transforms := reference.ChildElement(XMLDsigNamespace, "Transforms"); transforms != nil {
+		for _, transform := range transforms.ChildElements(XMLDsigNamespace, "Transform") {
+			switch transform.Attr("Algorithm") {
+			case XMLEnvelopedSignatureTransform:
+				isEnveloped = true
+			case XMLExcC14NAlgorithm:
+				inclusivePrefixes = getInclusivePrefixes(transform)
+			default:
+				return fmt.Errorf("unsupported transform: %s", transform.Attr("Algorithm"))
+			}
+		}
+	}
+	if !isEnveloped {
+		return fmt.Errorf("signature is not enveloped")
+	}
+
+	digestMethod := reference.ChildElement(XMLDsigNamespace, "DigestMethod")
+	if digestMethod == nil {
+		return fmt.Errorf("signature reference has no DigestMethod")
+	}
+	var digest hash.Hash
+	switch digestMethod.Attr("Algorithm") {
+	case XMLDsigSha1Algorithm:
+		digest = sha1.New()
+	case XMLDsigSha256Algorithm:
+		digest = sha256.New()
+	case XMLDsigSha512Algorithm:
+		digest = sha512.New()
+	default:
+		return fmt.Errorf("unsupported digest method: %s", digestMethod.Attr("Algorithm"))
+	}
+	digestValueElement := reference.ChildElement(XMLDsigNamespace, "DigestValue")
+	if digestValueElement == nil {
+		return fmt.Errorf("signature reference has no DigestValue")
+	}
+	digestValue, err := decodeXMLBase64(digestValueElement.Text())
+	if err != nil {
+		return fmt.Errorf("failed to decode DigestValue: %s", err)
+	}
+	digest.Write(e.ExclusiveCanonicalize(inclusivePrefixes, signature))
+	if !bytes.Equal(digest.Sum(nil), digestValue) {
+		return fmt.Errorf("digest mismatch")
+	}
+
+	signatureValueElement := signature.ChildElement(XMLDsigNamespace, "SignatureValue")
+	if signatureValueElement == nil {
+		return fmt.Errorf("signature has no SignatureValue")
+	}
+	signatureValue, err := decodeXMLBase64(signatureValueElement.Text())
+	if err != nil {
+		return fmt.Errorf("failed to decode SignatureValue: %s", err)
+	}
+	h := signatureHash.New()
+	h.Write(signedInfo.ExclusiveCanonicalize(getInclusivePrefixes(c14nMethod), nil))
+	hashed := h.Sum(nil)
+	for _, cert := range certs {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		if err := rsa.VerifyPKCS1v15(pub, signatureHash, hashed, signatureValue); err == nil {
+			log.Debugf("SAML %s signature verified with certificate %s", e.Local, cert.Subject)
+			return nil
+		}
+	}
+	return fmt.Errorf("signature does not match any of the IdP signing certificates")
+}
+
+// getInclusivePrefixes returns the prefixes in InclusiveNamespaces PrefixList
+// of a canonicalization method or transform.
+func getInclusivePrefixes(e *xmlElement) []string {
+	inclusiveNamespaces := e.ChildElement(XMLExcC14NNamespace, "InclusiveNamespaces")
+	if inclusiveNamespaces == nil {
+		return nil
+	}
+	return strings.Fields(inclusiveNamespaces.Attr("PrefixList"))
+}
+
+// decodeXMLBase64 decodes base64-encoded value of an XML element. The
+// value may contain whitespace.
+func decodeXMLBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
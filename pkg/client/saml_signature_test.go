@@ -0,0 +1,79 @@
+package client
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestVerifySamlResponseSignature(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	for i, test := range []struct {
+		input     string
+		metadata  string
+		shouldErr bool
+	}{
+		{
+			input:     "saml2.response.signed.xml",
+			metadata:  "adfs.federation.metadata.xml",
+			shouldErr: false,
+		},
+		{
+			input:     "saml2.response.tampered.xml",
+			metadata:  "adfs.federation.metadata.xml",
+			shouldErr: true,
+		},
+		{
+			input:     "saml2.response.signed.xml",
+			metadata:  "adfs.federation.metadata.untrusted.xml",
+			shouldErr: true,
+		},
+		{
+			input:     "saml2.response.xml",
+			metadata:  "adfs.federation.metadata.xml",
+			shouldErr: true,
+		},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		mfp := path.Join(assetDir, test.metadata)
+		metadata := &SamlServiceMetadata{}
+		metadata.Raw, err = ioutil.ReadFile(mfp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, mfp, err)
+			testFailed++
+			continue
+		}
+		certs, err := metadata.GetSigningCertificates()
+		if err != nil {
+			t.Logf("FAIL: Test %d: metadata '%s', failed to get signing certificates: %v", i, test.metadata, err)
+			testFailed++
+			continue
+		}
+		err = VerifySamlResponseSignature(content, certs)
+		if err != nil {
+			if !test.shouldErr {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input '%s', expected to throw error, threw: %v", i, test.input, err)
+			continue
+		}
+		if test.shouldErr {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw error, but passed", i, test.input)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// AwsSamlConsumerURL is AWS SAML assertion consumer service URL.
+	AwsSamlConsumerURL = "https://signin.aws.amazon.com/saml"
+	// AwsRelyingPartyURN is the identifier of AWS as SAML relying party.
+	AwsRelyingPartyURN = "urn:amazon:webservices"
+	// DefaultSamlClockSkew is the allowed difference between the clocks
+	// of the IdP and the local host.
+	DefaultSamlClockSkew = 3 * time.Minute
+)
+
+// SamlAssertionNotYetValidError is returned when the conditions of
+// SAML Assertion are not valid yet, i.e. NotBefore is in the future.
+type SamlAssertionNotYetValidError struct {
+	NotBefore time.Time
+	Now       time.Time
+}
+
+func (e *SamlAssertionNotYetValidError) Error() string {
+	return fmt.Sprintf("SAML Assertion is not valid before %s, the current time is %s", e.NotBefore, e.Now)
+}
+
+// SamlAssertionExpiredError is returned when the conditions of SAML
+// Assertion are no longer valid, i.e. NotOnOrAfter is in the past.
+type SamlAssertionExpiredError struct {
+	NotOnOrAfter time.Time
+	Now          time.Time
+}
+
+func (e *SamlAssertionExpiredError) Error() string {
+	return fmt.Sprintf("SAML Assertion expired at %s, the current time is %s", e.NotOnOrAfter, e.Now)
+}
+
+// SamlSubjectConfirmationExpiredError is returned when the time to present
+// SAML Assertion to AWS, i.e. NotOnOrAfter of SubjectConfirmationData,
+// passed.
+type SamlSubjectConfirmationExpiredError struct {
+	NotOnOrAfter time.Time
+	Now          time.Time
+}
+
+func (e *SamlSubjectConfirmationExpiredError) Error() string {
+	return fmt.Sprintf("SAML Assertion had to be presented to AWS by %s, the current time is %s", e.NotOnOrAfter, e.Now)
+}
+
+// SamlRecipientMismatchError is returned when the recipient of SAML
+// Assertion is not AWS assertion consumer service.
+type SamlRecipientMismatchError struct {
+	Recipient string
+	Expected  []string
+}
+
+func (e *SamlRecipientMismatchError) Error() string {
+	return fmt.Sprintf("SAML Assertion recipient %q does not match %s", e.Recipient, strings.Join(e.Expected, " or "))
+}
+
+// SamlAudienceMismatchError is returned when SAML Assertion is not
+// intended for AWS.
+type SamlAudienceMismatchError struct {
+	Audiences []string
+	Expected  []string
+}
+
+func (e *SamlAudienceMismatchError) Error() string {
+	return fmt.Sprintf("SAML Assertion audiences %q do not include %s", e.Audiences, strings.Join(e.Expected, " or "))
+}
+
+// SamlStatusError is returned when the status of SAML Response is not
+// success.
+type SamlStatusError struct {
+	StatusCode string
+}
+
+func (e *SamlStatusError) Error() string {
+	return fmt.Sprintf("SAML Response status is %q, not success", e.StatusCode)
+}
+
+// SamlValidationOptions are the parameters of SAML Response validation.
+type SamlValidationOptions struct {
+	Now        time.Time
+	ClockSkew  time.Duration
+	Recipients []string
+	Audiences  []string
+}
+
+// Validate checks the status, the validity period, the recipient and the
+// audience of SAML Response.
+func (d *SamlResponseData) Validate(opts *SamlValidationOptions) error {
+	if !d.Success {
+		return &SamlStatusError{StatusCode: d.StatusCode}
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC()
+	if !d.Aws.SessionStartTimestamp.IsZero() && now.Add(opts.ClockSkew).Before(d.Aws.SessionStartTimestamp) {
+		return &SamlAssertionNotYetValidError{NotBefore: d.Aws.SessionStartTimestamp, Now: now}
+	}
+	if !d.Aws.SessionEndTimestamp.IsZero() && !now.Add(-1*opts.ClockSkew).Before(d.Aws.SessionEndTimestamp) {
+		return &SamlAssertionExpiredError{NotOnOrAfter: d.Aws.SessionEndTimestamp, Now: now}
+	}
+	if !d.Aws.AuthenticateByTimestamp.IsZero() && !now.Add(-1*opts.ClockSkew).Before(d.Aws.AuthenticateByTimestamp) {
+		return &SamlSubjectConfirmationExpiredError{NotOnOrAfter: d.Aws.AuthenticateByTimestamp, Now: now}
+	}
+	if len(opts.Recipients) > 0 && !isSamlRecipientAllowed(opts.Recipients, d.Recipient) {
+		return &SamlRecipientMismatchError{Recipient: d.Recipient, Expected: opts.Recipients}
+	}
+	if len(opts.Audiences) > 0 {
+		isAudienceFound := false
+		for _, audience := range d.Audiences {
+			if containsString(opts.Audiences, audience) {
+				isAudienceFound = true
+				break
+			}
+		}
+		if !isAudienceFound {
+			return &SamlAudienceMismatchError{Audiences: d.Audiences, Expected: opts.Audiences}
+		}
+	}
+	return nil
+}
+
+// isSamlRecipientAllowed checks whether the recipient matches one of the
+// expected assertion consumer service URLs, including their regional
+// variants, e.g. https://us-east-2.signin.aws.amazon.com/saml.
+func isSamlRecipientAllowed(expected []string, recipient string) bool {
+	r, err := url.Parse(recipient)
+	if err != nil || r.Host == "" {
+		return false
+	}
+	for _, s := range expected {
+		u, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+		if r.Scheme != u.Scheme || strings.TrimSuffix(r.Path, "/") != strings.TrimSuffix(u.Path, "/") {
+			continue
+		}
+		if r.Host == u.Host {
+			return true
+		}
+		if region := strings.TrimSuffix(r.Host, "."+u.Host); region != r.Host && !strings.Contains(region, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(arr []string, s string) bool {
+	for _, v := range arr {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
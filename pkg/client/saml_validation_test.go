@@ -0,0 +1,163 @@
+package client
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSamlResponseData(t *testing.T) {
+	testFailed := 0
+	assetDir := "../../assets/tests"
+	var errNotYetValid *SamlAssertionNotYetValidError
+	var errExpired *SamlAssertionExpiredError
+	var errSubjectConfirmationExpired *SamlSubjectConfirmationExpiredError
+	var errRecipientMismatch *SamlRecipientMismatchError
+	var errAudienceMismatch *SamlAudienceMismatchError
+	var errStatus *SamlStatusError
+	for i, test := range []struct {
+		input      string
+		now        string
+		recipients []string
+		audiences  []string
+		failed     bool
+		expErr     interface{}
+	}{
+		{
+			input: "saml2.response.xml",
+			now:   "2019-09-07T10:00:00Z",
+		},
+		{
+			input:  "saml2.response.xml",
+			now:    "2019-09-07T09:40:00Z",
+			expErr: &errNotYetValid,
+		},
+		{
+			input:  "saml2.response.xml",
+			now:    "2019-09-07T12:00:00Z",
+			expErr: &errExpired,
+		},
+		{
+			input:  "saml2.response.signed.xml",
+			now:    "2019-09-07T10:10:00Z",
+			expErr: &errSubjectConfirmationExpired,
+		},
+		{
+			input:      "saml2.response.signed.xml",
+			now:        "2019-09-07T10:00:00Z",
+			recipients: []string{"https://signin.amazonaws-us-gov.com/saml"},
+			expErr:     &errRecipientMismatch,
+		},
+		{
+			input:     "saml2.response.signed.xml",
+			now:       "2019-09-07T10:00:00Z",
+			audiences: []string{AwsSamlConsumerURL},
+			expErr:    &errAudienceMismatch,
+		},
+		{
+			input:  "saml2.response.signed.xml",
+			now:    "2019-09-07T10:00:00Z",
+			failed: true,
+			expErr: &errStatus,
+		},
+	} {
+		fp := path.Join(assetDir, test.input)
+		content, err := ioutil.ReadFile(fp)
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed reading '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		resp := &SamlResponse{}
+		if err := xml.Unmarshal(content, resp); err != nil {
+			t.Logf("FAIL: Test %d: failed parsing '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		data, err := resp.GetAttributes()
+		if err != nil {
+			t.Logf("FAIL: Test %d: failed getting attributes from '%s', error: %v", i, fp, err)
+			testFailed++
+			continue
+		}
+		if test.failed {
+			data.Success = false
+			data.StatusCode = "urn:oasis:names:tc:SAML:2.0:status:Responder"
+		}
+		now, _ := time.Parse(time.RFC3339, test.now)
+		opts := &SamlValidationOptions{
+			Now:        now,
+			ClockSkew:  DefaultSamlClockSkew,
+			Recipients: []string{AwsSamlConsumerURL},
+			Audiences:  []string{AwsRelyingPartyURN},
+		}
+		if test.recipients != nil {
+			opts.Recipients = test.recipients
+		}
+		if test.audiences != nil {
+			opts.Audiences = test.audiences
+		}
+		err = data.Validate(opts)
+		if test.expErr == nil {
+			if err != nil {
+				t.Logf("FAIL: Test %d: input '%s', expected to pass, but threw error: %v", i, test.input, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: input '%s', expected to pass, passed", i, test.input)
+			continue
+		}
+		if err == nil || !errors.As(err, test.expErr) {
+			t.Logf("FAIL: Test %d: input '%s', expected to throw %T, but threw: %v", i, test.input, test.expErr, err)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: input '%s', expected to throw error, threw: %v", i, test.input, err)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
+
+func TestIsSamlAssertionValidAudience(t *testing.T) {
+	testFailed := 0
+	for i, test := range []struct {
+		audience   string
+		shouldFail bool
+	}{
+		{audience: AwsRelyingPartyURN},
+		// The assertion consumer service URL is not the audience of AWS.
+		{audience: AwsSamlConsumerURL, shouldFail: true},
+	} {
+		cli := New()
+		content := strings.Replace(string(getTestSamlResponse(t, time.Now().Add(time.Hour))),
+			"<Audience>"+AwsRelyingPartyURN+"</Audience>", "<Audience>"+test.audience+"</Audience>", 1)
+		if err := cli.loadSamlAssertions([]byte(content)); err != nil {
+			t.Fatalf("failed loading SAML response: %v", err)
+		}
+		err := cli.IsSamlAssertionValid()
+		if err != nil {
+			var errAudienceMismatch *SamlAudienceMismatchError
+			if !test.shouldFail || !errors.As(err, &errAudienceMismatch) {
+				t.Logf("FAIL: Test %d: audience %s, unexpected error: %v", i, test.audience, err)
+				testFailed++
+				continue
+			}
+			t.Logf("PASS: Test %d: audience %s, expected to fail, failed: %v", i, test.audience, err)
+			continue
+		}
+		if test.shouldFail {
+			t.Logf("FAIL: Test %d: audience %s, expected to fail, but passed", i, test.audience)
+			testFailed++
+			continue
+		}
+		t.Logf("PASS: Test %d: audience %s, expected to pass, passed", i, test.audience)
+	}
+	if testFailed > 0 {
+		t.Fatalf("Failed %d tests", testFailed)
+	}
+}
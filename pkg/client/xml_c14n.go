@@ -0,0 +1,307 @@
+package client
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// xmlElement is an element of XML document. Unlike the elements produced
+// by encoding/xml, it preserves namespace prefixes and declarations,
+// which are necessary for XML canonicalization.
+type xmlElement struct {
+	Prefix   string
+	Local    string
+	Attrs    []xml.Attr
+	NsDecls  map[string]string
+	Children []*xmlContent
+	Parent   *xmlElement
+}
+
+// xmlContent is either a child element or character data.
+type xmlContent struct {
+	Element *xmlElement
+	Text    string
+}
+
+// parseXMLDocument parses an input byte array and returns the root
+// element of the document.
+func parseXMLDocument(s []byte) (*xmlElement, error) {
+	var root, current *xmlElement
+	d := xml.NewDecoder(bytes.NewReader(s))
+	d.Strict = true
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse XML document: %s", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &xmlElement{
+				Prefix:  t.Name.Space,
+				Local:   t.Name.Local,
+				NsDecls: map[string]string{},
+				Parent:  current,
+			}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					e.NsDecls[""] = attr.Value
+				case attr.Name.Space == "xmlns":
+					e.NsDecls[attr.Name.Local] = attr.Value
+				default:
+					e.Attrs = append(e.Attrs, attr)
+				}
+			}
+			if current == nil {
+				if root != nil {
+					return nil, fmt.Errorf("Failed to parse XML document: multiple root elements")
+				}
+				root = e
+			} else {
+				current.Children = append(current.Children, &xmlContent{Element: e})
+			}
+			current = e
+		case xml.EndElement:
+			if current == nil || current.Prefix != t.Name.Space || current.Local != t.Name.Local {
+				return nil, fmt.Errorf("Failed to parse XML document: unexpected end element %s", t.Name.Local)
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, &xmlContent{Text: string(t)})
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("Failed to parse XML document: root element not found")
+	}
+	if current != nil {
+		return nil, fmt.Errorf("Failed to parse XML document: element %s is not closed", current.Local)
+	}
+	return root, nil
+}
+
+// LookupNamespace returns the namespace URI bound to a prefix in the
+// scope of the element.
+func (e *xmlElement) LookupNamespace(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespace
+	}
+	for n := e; n != nil; n = n.Parent {
+		if uri, exists := n.NsDecls[prefix]; exists {
+			return uri
+		}
+	}
+	return ""
+}
+
+// Namespace returns the namespace URI of the element.
+func (e *xmlElement) Namespace() string {
+	return e.LookupNamespace(e.Prefix)
+}
+
+// Is returns true when the element has the provided namespace URI and
+// local name.
+func (e *xmlElement) Is(ns, local string) bool {
+	return e.Local == local && e.Namespace() == ns
+}
+
+// Attr returns the value of an unqualified attribute.
+func (e *xmlElement) Attr(local string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// ChildElements returns the child elements having the provided namespace
+// URI and local name.
+func (e *xmlElement) ChildElements(ns, local string) []*xmlElement {
+	elements := []*xmlElement{}
+	for _, child := range e.Children {
+		if child.Element != nil && child.Element.Is(ns, local) {
+			elements = append(elements, child.Element)
+		}
+	}
+	return elements
+}
+
+// ChildElement returns the first child element having the provided
+// namespace URI and local name.
+func (e *xmlElement) ChildElement(ns, local string) *xmlElement {
+	for _, child := range e.Children {
+		if child.Element != nil && child.Element.Is(ns, local) {
+			return child.Element
+		}
+	}
+	return nil
+}
+
+// FindElements returns the descendants, including the element itself,
+// having the provided namespace URI and local name.
+func (e *xmlElement) FindElements(ns, local string) []*xmlElement {
+	elements := []*xmlElement{}
+	if e.Is(ns, local) {
+		elements = append(elements, e)
+	}
+	for _, child := range e.Children {
+		if child.Element != nil {
+			elements = append(elements, child.Element.FindElements(ns, local)...)
+		}
+	}
+	return elements
+}
+
+// Text returns the character data of the element and its descendants.
+func (e *xmlElement) Text() string {
+	var sb strings.Builder
+	for _, child := range e.Children {
+		if child.Element != nil {
+			sb.WriteString(child.Element.Text())
+			continue
+		}
+		sb.WriteString(child.Text)
+	}
+	return sb.String()
+}
+
+// ExclusiveCanonicalize returns exclusive XML canonicalization (without
+// comments) of the element, see https://www.w3.org/TR/xml-exc-c14n/.
+// The inclusive prefixes are the prefixes in InclusiveNamespaces PrefixList.
+// The excluded element, e.g. enveloped signature, is omitted from the output.
+func (e *xmlElement) ExclusiveCanonicalize(inclusivePrefixes []string, excluded *xmlElement) []byte {
+	inclusive := map[string]bool{}
+	for _, p := range inclusivePrefixes {
+		if p == "#default" {
+			p = ""
+		}
+		inclusive[p] = true
+	}
+	var buf bytes.Buffer
+	e.writeExclusiveCanonical(&buf, map[string]string{}, inclusive, excluded)
+	return buf.Bytes()
+}
+
+func (e *xmlElement) writeExclusiveCanonical(w *bytes.Buffer, rendered map[string]string, inclusive map[string]bool, excluded *xmlElement) {
+	// Determine the namespaces visibly utilized by the element and its attributes.
+	utilized := map[string]bool{e.Prefix: true}
+	for _, attr := range e.Attrs {
+		if attr.Name.Space != "" && attr.Name.Space != "xml" {
+			utilized[attr.Name.Space] = true
+		}
+	}
+	for p := range inclusive {
+		if e.LookupNamespace(p) != "" {
+			utilized[p] = true
+		}
+	}
+
+	scope := map[string]string{}
+	for k, v := range rendered {
+		scope[k] = v
+	}
+	prefixes := []string{}
+	for p := range utilized {
+		uri := e.LookupNamespace(p)
+		renderedURI, isRendered := scope[p]
+		if p == "" && uri == "" {
+			if !isRendered || renderedURI == "" {
+				continue
+			}
+		} else if uri == "" || (isRendered && renderedURI == uri) {
+			continue
+		}
+		scope[p] = uri
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	attrs := make([]xml.Attr, len(e.Attrs))
+	copy(attrs, e.Attrs)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		nsi, nsj := e.attrNamespace(attrs[i]), e.attrNamespace(attrs[j])
+		if nsi != nsj {
+			return nsi < nsj
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+
+	w.WriteString("<" + qualifiedXMLName(e.Prefix, e.Local))
+	for _, p := range prefixes {
+		if p == "" {
+			w.WriteString(" xmlns=\"")
+		} else {
+			w.WriteString(" xmlns:" + p + "=\"")
+		}
+		w.WriteString(escapeCanonicalAttr(scope[p]))
+		w.WriteString("\"")
+	}
+	for _, attr := range attrs {
+		w.WriteString(" " + qualifiedXMLName(attr.Name.Space, attr.Name.Local) + "=\"")
+		w.WriteString(escapeCanonicalAttr(attr.Value))
+		w.WriteString("\"")
+	}
+	w.WriteString(">")
+	for _, child := range e.Children {
+		if child.Element == nil {
+			w.WriteString(escapeCanonicalText(child.Text))
+			continue
+		}
+		if child.Element == excluded {
+			continue
+		}
+		child.Element.writeExclusiveCanonical(w, scope, inclusive, excluded)
+	}
+	w.WriteString("</" + qualifiedXMLName(e.Prefix, e.Local) + ">")
+}
+
+// attrNamespace returns the namespace URI of an attribute. Unqualified
+// attributes have no namespace.
+func (e *xmlElement) attrNamespace(attr xml.Attr) string {
+	if attr.Name.Space == "" {
+		return ""
+	}
+	return e.LookupNamespace(attr.Name.Space)
+}
+
+func qualifiedXMLName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var canonicalAttrReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	"\"", "&quot;",
+	"\t", "&#x9;",
+	"\n", "&#xA;",
+	"\r", "&#xD;",
+)
+
+var canonicalTextReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	"\r", "&#xD;",
+)
+
+func escapeCanonicalAttr(s string) string {
+	return canonicalAttrReplacer.Replace(s)
+}
+
+func escapeCanonicalText(s string) string {
+	return canonicalTextReplacer.Replace(s)
+}